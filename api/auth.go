@@ -1,17 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
 	"net/http"
 	"net/url"
-	"os"
 	"strings"
 	"time"
 )
 
-const baseApiUrl string = "https://api.intra.42.fr"
-
 type User struct {
 	ID             int         `json:"id"`
 	Email          string      `json:"email"`
@@ -198,60 +195,108 @@ type TokenResponse struct {
 	SecretValidUntil int    `json:"secret"`
 }
 
-func Me(bearer string) (*MeResponse, error) {
-	url := baseApiUrl + "/v2/me"
+func (c *Client) Me(ctx context.Context, bearer string) (*MeResponse, error) {
+	token := bearer
+	if strings.Split(bearer, " ")[0] == "Bearer" {
+		token = bearer[len("Bearer "):]
+	}
 
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+	body := MeResponse{}
+
+	if _, err := c.getJSON(ctx, token, c.baseURL+"/v2/me", &body); err != nil {
 		return nil, err
 	}
 
-	if strings.Split(bearer, " ")[0] != "Bearer" {
-		req.Header.Set("Authorization", "Bearer "+bearer)
-	} else {
-		req.Header.Set("Authorization", bearer)
-	}
+	return &body, nil
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+func (c *Client) GetServerToken(ctx context.Context) (*TokenResponse, error) {
+	return c.requestToken(ctx, url.Values{
+		"grant_type": {"client_credentials"},
+	}, true)
+}
+
+// AuthorizeURL returns the page of the intra where users log in, to be sent
+// back to redirectUri with a code and state. codeChallenge is the PKCE S256
+// challenge, or empty to go without PKCE.
+func (c *Client) AuthorizeURL(redirectUri string, state string, codeChallenge string) string {
+	query := url.Values{
+		"client_id":     {c.clientID},
+		"redirect_uri":  {redirectUri},
+		"response_type": {"code"},
+		"scope":         {"public"},
+		"state":         {state},
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("Server returned " + resp.Status)
+	if codeChallenge != "" {
+		query.Set("code_challenge", codeChallenge)
+		query.Set("code_challenge_method", "S256")
 	}
 
-	body := MeResponse{}
+	return c.baseURL + "/oauth/authorize?" + query.Encode()
+}
 
-	err = json.NewDecoder(resp.Body).Decode(&body)
-	if err != nil {
-		return nil, err
+// GetUserToken exchanges the code received on the OAuth callback for the
+// token of the user who logged in. codeVerifier is the PKCE verifier of the
+// login, if any.
+func (c *Client) GetUserToken(ctx context.Context, code string, state string, redirectUri string, codeVerifier string) (*TokenResponse, error) {
+	params := url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {redirectUri},
+		"state":        {state},
 	}
 
-	return &body, nil
+	if codeVerifier != "" {
+		params.Set("code_verifier", codeVerifier)
+	}
+
+	return c.requestToken(ctx, params, false)
+}
+
+// RefreshUserToken exchanges the refresh token of a user for a new token.
+func (c *Client) RefreshUserToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	return c.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	}, false)
 }
 
-func GetServerToken() (*TokenResponse, error) {
-	url, err := url.Parse(baseApiUrl + "/oauth/token")
+// requestToken sends params to the token endpoint. A code or refresh token
+// can only be exchanged once, so those requests are not retried.
+func (c *Client) requestToken(ctx context.Context, params url.Values, retry bool) (*TokenResponse, error) {
+	url, err := url.Parse(c.baseURL + "/oauth/token")
 	if err != nil {
 		return nil, err
 	}
 
 	query := url.Query()
 
-	query.Set("grant_type", "client_credentials")
-	query.Set("client_id", os.Getenv("FORTY_TWO_API_CLIENT"))
-	query.Set("client_secret", os.Getenv("FORTY_TWO_API_SECRET"))
+	for key, values := range params {
+		query[key] = values
+	}
+
+	query.Set("client_id", c.clientID)
+	query.Set("client_secret", c.clientSecret)
 
 	url.RawQuery = query.Encode()
 
-	resp, err := http.Post(url.String(), "application/json", nil)
+	req, err := http.NewRequestWithContext(ctx, "POST", url.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.do(req, retry)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("Server returned " + resp.Status)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
 	}
 
 	data := TokenResponse{}
@@ -1,14 +1,12 @@
 package api
 
 import (
-	"encoding/json"
-	"net/http"
-	"net/url"
+	"context"
 	"strconv"
 	"time"
 )
 
-type CampusResponse []struct {
+type Campus struct {
 	ID       int    `json:"id"`
 	Name     string `json:"name"`
 	TimeZone string `json:"time_zone"`
@@ -39,30 +37,12 @@ type CampusResponse []struct {
 	} `json:"endpoint"`
 }
 
-func GetCampusByID(token string, campusID int) (CampusResponse, error) {
-	url, err := url.Parse(baseApiUrl + "/v2/campus/" + strconv.Itoa(campusID))
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("GET", url.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	data := CampusResponse{}
+func (c *Client) GetCampusByID(ctx context.Context, campusID int) (*Campus, error) {
+	data := Campus{}
 
-	err = json.NewDecoder(resp.Body).Decode(&data)
-	if err != nil {
+	if _, err := c.getServerJSON(ctx, c.baseURL+"/v2/campus/"+strconv.Itoa(campusID), &data); err != nil {
 		return nil, err
 	}
 
-	return data, nil
+	return &data, nil
 }
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const DefaultBaseURL string = "https://api.intra.42.fr"
+
+// DefaultTimeout bounds each request to the API. Retries get a fresh timeout.
+const DefaultTimeout = 30 * time.Second
+
+// The intra allows 2 requests per second and 1200 per hour per application.
+const (
+	defaultPerSecond int = 2
+	defaultPerHour   int = 1200
+	defaultRetries   int = 4
+)
+
+// DefaultClient is shared by the whole server so that every request counts
+// against the same quota.
+var DefaultClient = NewDefaultClient(DefaultBaseURL, DefaultTimeout)
+
+type StatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return "Server returned " + e.Status
+}
+
+// Client sends requests to the 42 API, waiting on its limiter before each
+// request. Rate limited requests are retried once the API allows it, and
+// server errors are retried with exponential backoff, unless the request
+// cannot be safely sent twice.
+type Client struct {
+	baseURL      string
+	clientID     string
+	clientSecret string
+	http         *http.Client
+	limiter      *Limiter
+	tokens       *TokenSource
+	maxRetries   int
+}
+
+// NewClient creates a client sending requests to the API at baseURL, such as
+// DefaultBaseURL or the URL of a fake intra.
+func NewClient(baseURL string, httpClient *http.Client, limiter *Limiter) *Client {
+	client := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		http:       httpClient,
+		limiter:    limiter,
+		maxRetries: defaultRetries,
+	}
+
+	client.tokens = NewTokenSource(client)
+
+	return client
+}
+
+// NewDefaultClient creates a client for the API at baseURL with the intra
+// quotas, giving up on a request after timeout.
+func NewDefaultClient(baseURL string, timeout time.Duration) *Client {
+	return NewClient(baseURL, &http.Client{Timeout: timeout}, NewLimiter(defaultPerSecond, defaultPerHour))
+}
+
+// SetCredentials sets the credentials of the application, sent when
+// requesting tokens.
+func (c *Client) SetCredentials(clientID string, clientSecret string) {
+	c.clientID = clientID
+	c.clientSecret = clientSecret
+}
+
+// BaseURL returns the URL of the API the client talks to.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// Tokens returns the source of the server token used by the requests made on
+// behalf of the application.
+func (c *Client) Tokens() *TokenSource {
+	return c.tokens
+}
+
+// Stats counts the requests made on behalf of a context, see WithStats.
+type Stats struct {
+	Calls   atomic.Int64
+	Retries atomic.Int64
+}
+
+type statsKey struct{}
+
+// WithStats returns a context in which every request made by a Client is
+// counted in stats.
+func WithStats(ctx context.Context, stats *Stats) context.Context {
+	return context.WithValue(ctx, statsKey{}, stats)
+}
+
+func statsFrom(ctx context.Context) *Stats {
+	stats, _ := ctx.Value(statsKey{}).(*Stats)
+	return stats
+}
+
+// do sends req, retrying on 429 and 5xx responses and on network errors if
+// retry is set. Only requests that are safe to send twice may be retried. The
+// request must not have a body. On success the caller must close the body.
+func (c *Client) do(req *http.Request, retry bool) (*http.Response, error) {
+	ctx := req.Context()
+	stats := statsFrom(ctx)
+
+	maxRetries := c.maxRetries
+	if !retry {
+		maxRetries = 0
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		if stats != nil {
+			stats.Calls.Add(1)
+
+			if attempt > 0 {
+				stats.Retries.Add(1)
+			}
+		}
+
+		resp, err := c.http.Do(req)
+		if err == nil && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+			return resp, nil
+		}
+
+		if err == nil {
+			resp.Body.Close()
+			err = &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		}
+
+		delay := backoff(attempt)
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			if wait, ok := retryAfter(resp.Header, time.Now()); ok {
+				delay = wait
+			}
+
+			c.limiter.Pause(time.Now().Add(delay))
+		}
+
+		if attempt >= maxRetries || ctx.Err() != nil {
+			return nil, err
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// getJSON sends an authenticated GET request to url and decodes the response
+// into v.
+func (c *Client) getJSON(ctx context.Context, token string, url string, v interface{}) (http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.do(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+
+	return resp.Header, json.NewDecoder(resp.Body).Decode(v)
+}
+
+// getServerJSON is like getJSON with the cached server token. If the API
+// rejects the token, the request is retried once with a fresh one.
+func (c *Client) getServerJSON(ctx context.Context, url string, v interface{}) (http.Header, error) {
+	token, err := c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := c.getJSON(ctx, token, url, v)
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		return header, err
+	}
+
+	c.tokens.Invalidate(token)
+
+	token, err = c.tokens.Token(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.getJSON(ctx, token, url, v)
+}
+
+// backoff returns the delay before the given retry: one second doubled on
+// every attempt, with up to 50% jitter.
+func backoff(attempt int) time.Duration {
+	delay := time.Second << attempt
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/2))
+}
+
+// retryAfter reads how long to wait from a 429 response, using Retry-After
+// (in seconds or as an HTTP date) or else X-RateLimit-Reset (in seconds or as
+// a unix timestamp).
+func retryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	if value := header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil {
+			return time.Duration(seconds) * time.Second, true
+		}
+
+		if date, err := http.ParseTime(value); err == nil {
+			return date.Sub(now), true
+		}
+	}
+
+	if header.Get("X-RateLimit-Remaining") == "0" {
+		if reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+			if reset > now.Unix() {
+				return time.Unix(reset, 0).Sub(now), true
+			}
+
+			return time.Duration(reset) * time.Second, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   time.Duration
+		ok     bool
+	}{
+		{
+			name:   "seconds",
+			header: http.Header{"Retry-After": {"7"}},
+			want:   7 * time.Second,
+			ok:     true,
+		},
+		{
+			name:   "HTTP date",
+			header: http.Header{"Retry-After": {now.Add(90 * time.Second).Format(http.TimeFormat)}},
+			want:   90 * time.Second,
+			ok:     true,
+		},
+		{
+			name: "Retry-After wins over the reset",
+			header: http.Header{
+				"Retry-After":           {"3"},
+				"X-Ratelimit-Remaining": {"0"},
+				"X-Ratelimit-Reset":     {"60"},
+			},
+			want: 3 * time.Second,
+			ok:   true,
+		},
+		{
+			name: "reset in seconds",
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"0"},
+				"X-Ratelimit-Reset":     {"42"},
+			},
+			want: 42 * time.Second,
+			ok:   true,
+		},
+		{
+			name: "reset as a unix timestamp",
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"0"},
+				"X-Ratelimit-Reset":     {strconv.FormatInt(now.Add(time.Minute).Unix(), 10)},
+			},
+			want: time.Minute,
+			ok:   true,
+		},
+		{
+			name: "reset with requests remaining",
+			header: http.Header{
+				"X-Ratelimit-Remaining": {"5"},
+				"X-Ratelimit-Reset":     {"42"},
+			},
+		},
+		{
+			name:   "invalid Retry-After",
+			header: http.Header{"Retry-After": {"soon"}},
+		},
+		{
+			name:   "no header",
+			header: http.Header{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := retryAfter(tt.header, now)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("retryAfter = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
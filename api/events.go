@@ -1,9 +1,7 @@
 package api
 
 import (
-	"encoding/json"
-	"errors"
-	"net/http"
+	"context"
 	"net/url"
 	"strconv"
 	"time"
@@ -27,35 +25,28 @@ type Event struct {
 
 type EventsResponse []Event
 
-func GetEventsByCampusID(token string, campusID int) (EventsResponse, error) {
-	url, err := url.Parse(baseApiUrl + "/v2/campus/" + strconv.Itoa(campusID) + "/events")
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("GET", url.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
+// farFuture bounds the ranges that are only meant to have a start, as the
+// intra wants both ends.
+var farFuture = time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+// EventsByCampusID returns a pager over the events of a campus that end after
+// endAfter, or every event if it is zero.
+func (c *Client) EventsByCampusID(campusID int, endAfter time.Time, pageSize int) *Pager[Event] {
+	var query url.Values
+	if !endAfter.IsZero() {
+		query = url.Values{"range[end_at]": {endAfter.UTC().Format(time.RFC3339) + "," + farFuture.Format(time.RFC3339)}}
 	}
 
-	data := EventsResponse{}
-
-	err = json.NewDecoder(resp.Body).Decode(&data)
-	if err != nil {
-		return nil, err
-	}
+	return newPager[Event](c, "/v2/campus/"+strconv.Itoa(campusID)+"/events", pageSize, query)
+}
 
-	return data, nil
+// GetEventsByCampusID returns every upcoming event of a campus, leaving out
+// the ones that already ended.
+func (c *Client) GetEventsByCampusID(ctx context.Context, campusID int) (EventsResponse, error) {
+	return c.EventsByCampusID(campusID, time.Now(), MaxPageSize).All(ctx)
 }
 
-type EventUsersResponse []struct {
+type EventUser struct {
 	ID      int   `json:"id"`
 	EventID int   `json:"event_id"`
 	UserID  int   `json:"user_id"`
@@ -63,59 +54,14 @@ type EventUsersResponse []struct {
 	Event   Event `json:"event"`
 }
 
-type Pagination struct {
-	PageNumber int
-	PageSize   int
-}
-
-func buildPagination(page *Pagination) string {
-	str := ""
-
-	if page.PageNumber <= 0 {
-		str = str + "?page[number]=1"
-	} else {
-		str = str + "?page[number]=" + strconv.Itoa(page.PageNumber)
-	}
-
-	if page.PageSize <= 0 {
-		str = str + "?page[number]=30"
-	} else if page.PageSize > 100 {
-		str = str + "?page[number]=100"
-	} else {
-		str = str + "?page[number]=" + strconv.Itoa(page.PageSize)
-	}
+type EventUsersResponse []EventUser
 
-	return str
+// EventUsersByEventID returns a pager over the users subscribed to an event.
+func (c *Client) EventUsersByEventID(eventID int, pageSize int) *Pager[EventUser] {
+	return newPager[EventUser](c, "/v2/events/"+strconv.Itoa(eventID)+"/events_users", pageSize, nil)
 }
 
-func GetEventUsersByEventId(token string, eventID int, page *Pagination) (EventUsersResponse, error) {
-	url, err := url.Parse(baseApiUrl + "/v2/events/" + strconv.Itoa(eventID) + "/events_users" + buildPagination(page))
-	if err != nil {
-		return nil, err
-	}
-
-	req, err := http.NewRequest("GET", url.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("Server returned " + resp.Status)
-	}
-
-	data := EventUsersResponse{}
-
-	err = json.NewDecoder(resp.Body).Decode(&data)
-	if err != nil {
-		return nil, err
-	}
-
-	return data, nil
+// GetEventUsersByEventId returns every user subscribed to an event.
+func (c *Client) GetEventUsersByEventId(ctx context.Context, eventID int) (EventUsersResponse, error) {
+	return c.EventUsersByEventID(eventID, MaxPageSize).All(ctx)
 }
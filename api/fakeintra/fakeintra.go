@@ -0,0 +1,483 @@
+// Package fakeintra provides an in-process fake of the 42 intra API, serving
+// fixtures so that login and sync can be exercised without network access.
+package fakeintra
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/herbievine/42-events-api/api"
+)
+
+// Server is a fake intra listening on a local address. Its fields may be
+// changed between requests to tune its behaviour.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fixtures *Fixtures
+	tokens   map[string]int
+	refresh  map[string]int
+	next     int
+	failures []int
+	requests map[string]int
+
+	// challenges holds the PKCE challenge sent to /oauth/authorize for each
+	// code.
+	challenges map[string]string
+
+	// PageSize is used by list endpoints when the request sets no page[size].
+	PageSize int
+
+	// MaxPageSize caps the page[size] of list requests, as the intra does.
+	// Lowering it makes clients walk more pages.
+	MaxPageSize int
+
+	// RetryAfter is sent with the 429 responses queued by FailNext.
+	RetryAfter time.Duration
+
+	// TokenLifetime is the expires_in of the tokens issued by /oauth/token.
+	TokenLifetime time.Duration
+}
+
+// New starts a fake intra serving fixtures. It must be closed by the caller.
+func New(fixtures *Fixtures) *Server {
+	s := &Server{
+		fixtures:      fixtures,
+		tokens:        make(map[string]int),
+		refresh:       make(map[string]int),
+		challenges:    make(map[string]string),
+		requests:      make(map[string]int),
+		PageSize:      api.DefaultPageSize,
+		MaxPageSize:   api.MaxPageSize,
+		RetryAfter:    time.Second,
+		TokenLifetime: 2 * time.Hour,
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /oauth/authorize", s.authorize)
+	mux.HandleFunc("POST /oauth/token", s.token)
+	mux.HandleFunc("GET /v2/me", s.authenticated(s.me))
+	mux.HandleFunc("GET /v2/campus/{id}", s.authenticated(s.campus))
+	mux.HandleFunc("GET /v2/campus/{id}/events", s.authenticated(s.campusEvents))
+	mux.HandleFunc("GET /v2/events/{id}/events_users", s.authenticated(s.eventUsers))
+
+	s.Server = httptest.NewServer(s.intercept(mux))
+
+	return s
+}
+
+// APIClient returns an API client talking to the fake with the credentials
+// of the fixtures, and the limiter disabled.
+func (s *Server) APIClient() *api.Client {
+	client := api.NewClient(s.URL, s.Server.Client(), api.NewLimiter(0, 0))
+	client.SetCredentials(s.fixtures.ClientID, s.fixtures.ClientSecret)
+
+	return client
+}
+
+// FailNext makes the next n requests fail with status. A 429 comes with a
+// Retry-After header set from RetryAfter.
+func (s *Server) FailNext(n int, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// RevokeTokens invalidates every access token issued so far, so that the next
+// requests get a 401. Refresh tokens stay valid.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = make(map[string]int)
+}
+
+// Requests returns how many requests were received for the given path, such
+// as "/v2/campus/1/events", including failed ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[path]
+}
+
+// SetFixtures replaces the data served by the fake.
+func (s *Server) SetFixtures(fixtures *Fixtures) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.fixtures = fixtures
+}
+
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+
+		status := 0
+		if len(s.failures) > 0 {
+			status = s.failures[0]
+			s.failures = s.failures[1:]
+		}
+
+		retryAfter := s.RetryAfter
+		s.mu.Unlock()
+
+		if status == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+		}
+
+		http.Error(w, http.StatusText(status), status)
+	})
+}
+
+// authenticated rejects requests without a token issued by the fake. The ID
+// of the user the token belongs to, or zero for the server token, is passed
+// to next.
+func (s *Server) authenticated(next func(http.ResponseWriter, *http.Request, int)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		s.mu.Lock()
+		userID, known := s.tokens[token]
+		s.mu.Unlock()
+
+		if !ok || !known {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{
+				"error":             "invalid_token",
+				"error_description": "The access token is invalid",
+			})
+			return
+		}
+
+		next(w, r, userID)
+	}
+}
+
+// authorize logs in straight away, redirecting with the code given in the
+// query, or else the first code of the fixtures.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	redirectURI, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirectURI.Scheme == "" || query.Get("response_type") != "code" {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.fixtures.ClientID != "" && query.Get("client_id") != s.fixtures.ClientID {
+		http.Error(w, "Unknown client", http.StatusUnauthorized)
+		return
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		for c := range s.fixtures.Users {
+			if code == "" || c < code {
+				code = c
+			}
+		}
+	}
+
+	if challenge := query.Get("code_challenge"); challenge != "" {
+		if query.Get("code_challenge_method") != "S256" {
+			http.Error(w, "Unsupported code challenge method", http.StatusBadRequest)
+			return
+		}
+
+		s.challenges[code] = challenge
+	}
+
+	callback := redirectURI.Query()
+	callback.Set("code", code)
+	callback.Set("state", query.Get("state"))
+	redirectURI.RawQuery = callback.Encode()
+
+	http.Redirect(w, r, redirectURI.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clientID, clientSecret := r.Form.Get("client_id"), r.Form.Get("client_secret")
+	if s.fixtures.ClientID != "" && (clientID != s.fixtures.ClientID || clientSecret != s.fixtures.ClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	userID := 0
+
+	switch r.Form.Get("grant_type") {
+	case "client_credentials":
+	case "authorization_code":
+		code := r.Form.Get("code")
+
+		user, ok := s.fixtures.Users[code]
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		if challenge, ok := s.challenges[code]; ok {
+			sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+
+			if base64.RawURLEncoding.EncodeToString(sum[:]) != challenge {
+				writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_grant"})
+				return
+			}
+
+			delete(s.challenges, code)
+		}
+
+		userID = user.ID
+	case "refresh_token":
+		// Like the intra, a refresh token can only be used once.
+		refreshToken := r.Form.Get("refresh_token")
+
+		id, ok := s.refresh[refreshToken]
+		if !ok {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		delete(s.refresh, refreshToken)
+		userID = id
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+		return
+	}
+
+	s.next++
+	token := fmt.Sprintf("fake-token-%d", s.next)
+	s.tokens[token] = userID
+
+	resp := api.TokenResponse{
+		AccessToken: token,
+		TokenType:   "bearer",
+		ExpiresIn:   int(s.TokenLifetime.Seconds()),
+		Scope:       "public",
+		CreatedAt:   int(time.Now().Unix()),
+	}
+
+	if userID != 0 {
+		resp.RefreshToken = fmt.Sprintf("fake-refresh-%d", s.next)
+		s.refresh[resp.RefreshToken] = userID
+	}
+
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) me(w http.ResponseWriter, r *http.Request, userID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, user := range s.fixtures.Users {
+		if user.ID == userID && userID != 0 {
+			writeJSON(w, http.StatusOK, user)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusNotFound, map[string]string{})
+}
+
+func (s *Server) campus(w http.ResponseWriter, r *http.Request, _ int) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	campus, ok := s.fixtures.Campuses[id]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, campus)
+}
+
+func (s *Server) campusEvents(w http.ResponseWriter, r *http.Request, _ int) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.fixtures.Campuses[id]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{})
+		return
+	}
+
+	from, to, ok := parseRange(r.URL.Query().Get("range[end_at]"))
+	if !ok {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	events := api.EventsResponse{}
+	for _, event := range s.fixtures.Events[id] {
+		if !event.EndAt.Before(from) && !event.EndAt.After(to) {
+			events = append(events, event)
+		}
+	}
+
+	s.writePage(w, r, len(events), func(start, end int) interface{} {
+		return events[start:end]
+	})
+}
+
+func (s *Server) eventUsers(w http.ResponseWriter, r *http.Request, _ int) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := s.fixtures.EventUsers[id]
+	if users == nil {
+		users = api.EventUsersResponse{}
+	}
+
+	s.writePage(w, r, len(users), func(start, end int) interface{} {
+		return users[start:end]
+	})
+}
+
+// parseRange parses a range filter such as range[end_at], made of two times
+// separated by a comma. An empty filter matches every time.
+func parseRange(value string) (time.Time, time.Time, bool) {
+	if value == "" {
+		return time.Time{}, time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC), true
+	}
+
+	min, max, ok := strings.Cut(value, ",")
+	if !ok {
+		return time.Time{}, time.Time{}, false
+	}
+
+	from, err := time.Parse(time.RFC3339, min)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	to, err := time.Parse(time.RFC3339, max)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+
+	return from, to, true
+}
+
+// writePage writes the requested page of a list of total items, along with
+// the pagination headers of the intra.
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, total int, slice func(start, end int) interface{}) {
+	query := r.URL.Query()
+
+	number, size := 1, min(s.PageSize, s.MaxPageSize)
+
+	if value := query.Get("page[number]"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		number = n
+	}
+
+	if value := query.Get("page[size]"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		size = min(n, s.MaxPageSize)
+	}
+
+	start := min((number-1)*size, total)
+	end := min(start+size, total)
+
+	w.Header().Set("X-Total", strconv.Itoa(total))
+	w.Header().Set("X-Page", strconv.Itoa(number))
+	w.Header().Set("X-Per-Page", strconv.Itoa(size))
+	w.Header().Set("Link", links("http://"+r.Host, r.URL, number, size, total))
+
+	writeJSON(w, http.StatusOK, slice(start, end))
+}
+
+// links builds a Link header pointing to the first, previous, next and last
+// pages, the way the intra does.
+func links(base string, u *url.URL, number int, size int, total int) string {
+	last := (total + size - 1) / size
+	if last < 1 {
+		last = 1
+	}
+
+	page := func(n int, rel string) string {
+		query := u.Query()
+		query.Set("page[number]", strconv.Itoa(n))
+		query.Set("page[size]", strconv.Itoa(size))
+
+		return fmt.Sprintf("<%s%s?%s>; rel=\"%s\"", base, u.Path, query.Encode(), rel)
+	}
+
+	parts := []string{page(1, "first")}
+
+	if number > 1 {
+		parts = append(parts, page(min(number-1, last), "prev"))
+	}
+
+	if number < last {
+		parts = append(parts, page(number+1, "next"))
+	}
+
+	parts = append(parts, page(last, "last"))
+
+	return strings.Join(parts, ", ")
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	json.NewEncoder(w).Encode(v)
+}
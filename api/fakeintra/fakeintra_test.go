@@ -0,0 +1,130 @@
+package fakeintra_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/herbievine/42-events-api/api"
+	"github.com/herbievine/42-events-api/api/fakeintra"
+)
+
+func TestLogin(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	client := fake.APIClient()
+
+	token, err := client.GetUserToken(ctx, "fake-code", "state", "http://frontend.test/callback", "")
+	if err != nil {
+		t.Fatalf("GetUserToken: %v", err)
+	}
+
+	if token.AccessToken == "" || token.RefreshToken == "" {
+		t.Fatalf("GetUserToken returned %+v, want an access and a refresh token", token)
+	}
+
+	me, err := client.Me(ctx, "Bearer "+token.AccessToken)
+	if err != nil {
+		t.Fatalf("Me: %v", err)
+	}
+
+	if me.ID != 1001 || me.Login != "jdoe" || len(me.Campus) != 1 || me.Campus[0].ID != 1 {
+		t.Errorf("Me returned user %d %s of campuses %+v, want user 1001 jdoe of campus 1", me.ID, me.Login, me.Campus)
+	}
+
+	var statusErr *api.StatusError
+	if _, err := client.GetUserToken(ctx, "unknown-code", "state", "http://frontend.test/callback", ""); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GetUserToken with an unknown code returned %v, want a 401", err)
+	}
+
+	// A code can only be exchanged once, so a failed exchange is not retried.
+	fake.RetryAfter = 0
+	fake.FailNext(1, http.StatusServiceUnavailable)
+
+	requests := fake.Requests("/oauth/token")
+
+	if _, err := client.GetUserToken(ctx, "fake-code", "state", "http://frontend.test/callback", ""); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("GetUserToken during an outage returned %v, want a 503", err)
+	}
+
+	if n := fake.Requests("/oauth/token") - requests; n != 1 {
+		t.Errorf("GetUserToken sent %d requests during an outage, want 1", n)
+	}
+}
+
+func TestEventsPages(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	fake.MaxPageSize = 1
+
+	client := fake.APIClient()
+
+	all, err := client.EventsByCampusID(1, time.Time{}, api.MaxPageSize).All(ctx)
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+
+	if len(all) != 3 {
+		t.Errorf("EventsByCampusID without a range returned %d events, want 3", len(all))
+	}
+
+	if n := fake.Requests("/v2/campus/1/events"); n != 3 {
+		t.Errorf("listing every event took %d requests, want 3", n)
+	}
+
+	upcoming, err := client.GetEventsByCampusID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetEventsByCampusID: %v", err)
+	}
+
+	if len(upcoming) != 2 {
+		t.Errorf("GetEventsByCampusID returned %d events, want 2", len(upcoming))
+	}
+
+	// The page of the event that ended is not requested.
+	if n := fake.Requests("/v2/campus/1/events") - 3; n != 2 {
+		t.Errorf("listing the upcoming events took %d requests, want 2", n)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	fake.RetryAfter = 0
+	fake.FailNext(1, http.StatusTooManyRequests)
+	fake.FailNext(1, http.StatusServiceUnavailable)
+
+	client := fake.APIClient()
+
+	var stats api.Stats
+
+	events, err := client.GetEventsByCampusID(api.WithStats(ctx, &stats), 1)
+	if err != nil {
+		t.Fatalf("GetEventsByCampusID: %v", err)
+	}
+
+	// The event of 2020 is over, so only the upcoming ones are listed.
+	if len(events) != 2 || events[0].ID != 9001 || events[1].ID != 9002 {
+		t.Errorf("GetEventsByCampusID returned %+v, want events 9001 and 9002", events)
+	}
+
+	if retries := stats.Retries.Load(); retries != 2 {
+		t.Errorf("GetEventsByCampusID retried %d times, want 2", retries)
+	}
+
+	var statusErr *api.StatusError
+	if _, err := client.GetEventsByCampusID(ctx, 404); !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("GetEventsByCampusID of an unknown campus returned %v, want a 404", err)
+	}
+}
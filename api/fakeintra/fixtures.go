@@ -0,0 +1,60 @@
+package fakeintra
+
+import (
+	_ "embed"
+	"encoding/json"
+	"os"
+
+	"github.com/herbievine/42-events-api/api"
+)
+
+//go:embed fixtures.json
+var defaultFixtures []byte
+
+// Fixtures is the data served by the fake intra.
+type Fixtures struct {
+	// ClientID and ClientSecret are the application credentials accepted by
+	// /oauth/token. When empty, any credentials are accepted.
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+
+	// Users maps the authorization codes accepted by /oauth/token to the
+	// user logging in with them.
+	Users map[string]api.MeResponse `json:"users"`
+
+	// Campuses, Events and EventUsers are keyed by campus and event ID.
+	Campuses   map[int]api.Campus             `json:"campuses"`
+	Events     map[int]api.EventsResponse     `json:"events"`
+	EventUsers map[int]api.EventUsersResponse `json:"event_users"`
+}
+
+// DefaultFixtures returns a small data set with one user, two campuses and a
+// few events.
+func DefaultFixtures() *Fixtures {
+	fixtures, err := parseFixtures(defaultFixtures)
+	if err != nil {
+		panic("fakeintra: invalid default fixtures: " + err.Error())
+	}
+
+	return fixtures
+}
+
+// LoadFixtures reads fixtures from a JSON file.
+func LoadFixtures(path string) (*Fixtures, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseFixtures(data)
+}
+
+func parseFixtures(data []byte) (*Fixtures, error) {
+	fixtures := Fixtures{}
+
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, err
+	}
+
+	return &fixtures, nil
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// The intra's default and maximum page sizes.
+const (
+	DefaultPageSize int = 30
+	MaxPageSize     int = 100
+)
+
+// Pager walks the pages of a list endpoint, following the Link header of each
+// response, or else its X-Total and X-Per-Page headers. Use Next to fetch one
+// page at a time or All to collect every item.
+type Pager[T any] struct {
+	client *Client
+	next   string
+	total  int
+}
+
+// newPager returns a pager over the endpoint at path, requesting pages of
+// size items with the filters of query, which may be nil. The size is clamped
+// to the limits of the intra.
+func newPager[T any](c *Client, path string, size int, query url.Values) *Pager[T] {
+	if size <= 0 {
+		size = DefaultPageSize
+	} else if size > MaxPageSize {
+		size = MaxPageSize
+	}
+
+	if query == nil {
+		query = url.Values{}
+	}
+
+	query.Set("page[number]", "1")
+	query.Set("page[size]", strconv.Itoa(size))
+
+	return &Pager[T]{
+		client: c,
+		next:   c.baseURL + path + "?" + query.Encode(),
+		total:  -1,
+	}
+}
+
+// More reports whether there are pages left to fetch.
+func (p *Pager[T]) More() bool {
+	return p.next != ""
+}
+
+// Total returns the number of items announced by the API in X-Total, or -1
+// if no page was fetched yet or the header was missing.
+func (p *Pager[T]) Total() int {
+	return p.total
+}
+
+// Next fetches the next page. It returns no items once every page has been
+// fetched. On error, the same page is requested by the next call.
+func (p *Pager[T]) Next(ctx context.Context) ([]T, error) {
+	if p.next == "" {
+		return nil, nil
+	}
+
+	var items []T
+
+	header, err := p.client.getServerJSON(ctx, p.next, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	if total, err := strconv.Atoi(header.Get("X-Total")); err == nil {
+		p.total = total
+	}
+
+	p.next = nextPage(p.next, header, len(items))
+
+	return items, nil
+}
+
+// All fetches every remaining page and returns their items.
+func (p *Pager[T]) All(ctx context.Context) ([]T, error) {
+	all := make([]T, 0)
+
+	for p.More() {
+		items, err := p.Next(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, items...)
+	}
+
+	return all, nil
+}
+
+// nextPage returns the URL of the page following current, or an empty string
+// if current was the last one.
+func nextPage(current string, header http.Header, count int) string {
+	currentURL, err := url.Parse(current)
+	if err != nil {
+		return ""
+	}
+
+	if link := header.Get("Link"); link != "" {
+		next, ok := parseLinks(link)["next"]
+		if !ok {
+			return ""
+		}
+
+		nextURL, err := currentURL.Parse(next)
+		if err != nil || nextURL.String() == current {
+			return ""
+		}
+
+		return nextURL.String()
+	}
+
+	query := currentURL.Query()
+
+	number, err := strconv.Atoi(query.Get("page[number]"))
+	if err != nil {
+		return ""
+	}
+
+	size, err := strconv.Atoi(header.Get("X-Per-Page"))
+	if err != nil {
+		size, _ = strconv.Atoi(query.Get("page[size]"))
+	}
+
+	if total, err := strconv.Atoi(header.Get("X-Total")); err == nil {
+		if number*size >= total {
+			return ""
+		}
+	} else if count == 0 || count < size {
+		return ""
+	}
+
+	query.Set("page[number]", strconv.Itoa(number+1))
+	currentURL.RawQuery = query.Encode()
+
+	return currentURL.String()
+}
+
+// parseLinks maps the relations of a Link header to their URL.
+func parseLinks(header string) map[string]string {
+	links := make(map[string]string)
+
+	for _, part := range strings.Split(header, ",") {
+		target, params, ok := strings.Cut(strings.TrimSpace(part), ";")
+		if !ok {
+			continue
+		}
+
+		target = strings.TrimSpace(target)
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+
+		for _, param := range strings.Split(params, ";") {
+			key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+			if key == "rel" {
+				links[strings.Trim(value, `"`)] = target[1 : len(target)-1]
+			}
+		}
+	}
+
+	return links
+}
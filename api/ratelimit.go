@@ -0,0 +1,133 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	capacity float64
+	tokens   float64
+	rate     float64
+	last     time.Time
+}
+
+func (b *bucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+
+	b.last = now
+}
+
+// Limiter is a token bucket limiter enforcing both the per-second and the
+// per-hour quota of the 42 API. It is safe for concurrent use.
+type Limiter struct {
+	mu          sync.Mutex
+	buckets     []*bucket
+	pausedUntil time.Time
+}
+
+// NewLimiter creates a limiter allowing perSecond requests every second and
+// perHour requests every hour. A quota of zero or less is not enforced.
+func NewLimiter(perSecond int, perHour int) *Limiter {
+	now := time.Now()
+	limiter := &Limiter{}
+
+	if perSecond > 0 {
+		limiter.buckets = append(limiter.buckets, &bucket{
+			capacity: float64(perSecond),
+			tokens:   float64(perSecond),
+			rate:     float64(perSecond),
+			last:     now,
+		})
+	}
+
+	if perHour > 0 {
+		limiter.buckets = append(limiter.buckets, &bucket{
+			capacity: float64(perHour),
+			tokens:   float64(perHour),
+			rate:     float64(perHour) / time.Hour.Seconds(),
+			last:     now,
+		})
+	}
+
+	return limiter
+}
+
+// Wait blocks until a request may be sent or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		delay := l.reserve(time.Now())
+		if delay <= 0 {
+			return nil
+		}
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Pause holds back every request until the given time, typically after the
+// API answered with a 429.
+func (l *Limiter) Pause(until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+}
+
+// reserve takes a token from every bucket and returns zero, or returns how
+// long to wait before trying again.
+func (l *Limiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Before(l.pausedUntil) {
+		return l.pausedUntil.Sub(now)
+	}
+
+	var delay time.Duration
+	for _, b := range l.buckets {
+		b.refill(now)
+
+		if b.tokens < 1 {
+			wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+			if wait < time.Millisecond {
+				wait = time.Millisecond
+			}
+			if wait > delay {
+				delay = wait
+			}
+		}
+	}
+
+	if delay > 0 {
+		return delay
+	}
+
+	for _, b := range l.buckets {
+		b.tokens--
+	}
+
+	return 0
+}
+
+// sleep pauses for d, returning early with the context error if ctx is
+// cancelled in the meantime.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
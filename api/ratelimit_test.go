@@ -0,0 +1,58 @@
+package api
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	limiter := NewLimiter(2, 3)
+	now := limiter.buckets[0].last
+
+	// The buckets start full, so the first requests go through.
+	for i := 0; i < 2; i++ {
+		if delay := limiter.reserve(now); delay != 0 {
+			t.Fatalf("request %d waits %v, want 0", i+1, delay)
+		}
+	}
+
+	// The per-second bucket is empty and refills at 2 tokens per second.
+	if delay := limiter.reserve(now); delay != 500*time.Millisecond {
+		t.Errorf("third request waits %v, want 500ms", delay)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+
+	if delay := limiter.reserve(now); delay != 0 {
+		t.Errorf("third request waits %v after 500ms, want 0", delay)
+	}
+
+	// The per-hour bucket is now empty, refilling at 3 tokens per hour.
+	now = now.Add(time.Second)
+
+	if delay := limiter.reserve(now); delay < 19*time.Minute || delay > 20*time.Minute {
+		t.Errorf("fourth request waits %v, want about 20m", delay)
+	}
+}
+
+func TestLimiterPause(t *testing.T) {
+	limiter := NewLimiter(0, 0)
+	now := time.Now()
+
+	if delay := limiter.reserve(now); delay != 0 {
+		t.Fatalf("request waits %v without quotas, want 0", delay)
+	}
+
+	limiter.Pause(now.Add(time.Minute))
+
+	// An earlier pause does not shorten the current one.
+	limiter.Pause(now.Add(time.Second))
+
+	if delay := limiter.reserve(now); delay != time.Minute {
+		t.Errorf("request waits %v while paused, want 1m", delay)
+	}
+
+	if delay := limiter.reserve(now.Add(time.Minute)); delay != 0 {
+		t.Errorf("request waits %v once the pause is over, want 0", delay)
+	}
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// refreshMargin is how long before its expiry a cached token is replaced.
+const refreshMargin time.Duration = 5 * time.Minute
+
+// TokenSource caches the server's client credentials token and fetches a new
+// one shortly before it expires. It is safe for concurrent use, concurrent
+// callers waiting on a single refresh.
+type TokenSource struct {
+	client *Client
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func NewTokenSource(client *Client) *TokenSource {
+	return &TokenSource{client: client}
+}
+
+// Token returns a valid access token, fetching a new one if needed.
+func (ts *TokenSource) Token(ctx context.Context) (string, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token != "" && time.Now().Before(ts.expiry) {
+		return ts.token, nil
+	}
+
+	resp, err := ts.client.GetServerToken(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	createdAt := time.Now()
+	if resp.CreatedAt > 0 {
+		createdAt = time.Unix(int64(resp.CreatedAt), 0)
+	}
+
+	lifetime := time.Duration(resp.ExpiresIn) * time.Second
+	margin := refreshMargin
+	if margin > lifetime/10 {
+		margin = lifetime / 10
+	}
+
+	ts.token = resp.AccessToken
+	ts.expiry = createdAt.Add(lifetime - margin)
+
+	return ts.token, nil
+}
+
+// Invalidate drops token from the cache if it is still the current one, so
+// that the next call to Token fetches a new one.
+func (ts *TokenSource) Invalidate(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.token == token {
+		ts.token = ""
+	}
+}
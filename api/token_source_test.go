@@ -0,0 +1,111 @@
+package api_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/herbievine/42-events-api/api/fakeintra"
+)
+
+func TestTokenSourceCaches(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	tokens := fake.APIClient().Tokens()
+
+	first, err := tokens.Token(ctx)
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if token, err := tokens.Token(ctx); err != nil || token != first {
+				t.Errorf("Token = %q, %v, want the cached %q", token, err, first)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n := fake.Requests("/oauth/token"); n != 1 {
+		t.Errorf("requested %d tokens, want 1", n)
+	}
+
+	// Once the cached token is invalidated, a new one is fetched.
+	tokens.Invalidate(first)
+
+	if token, err := tokens.Token(ctx); err != nil || token == first {
+		t.Errorf("Token after Invalidate = %q, %v, want a new token", token, err)
+	}
+
+	if n := fake.Requests("/oauth/token"); n != 2 {
+		t.Errorf("requested %d tokens after Invalidate, want 2", n)
+	}
+}
+
+func TestTokenSourceRefreshesNearExpiry(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	// Tokens that expire right away are always within the refresh margin.
+	fake.TokenLifetime = 0
+
+	tokens := fake.APIClient().Tokens()
+
+	first, err := tokens.Token(ctx)
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+
+	if token, err := tokens.Token(ctx); err != nil || token == first {
+		t.Errorf("Token of an expiring token = %q, %v, want a new token", token, err)
+	}
+
+	if n := fake.Requests("/oauth/token"); n != 2 {
+		t.Errorf("requested %d tokens, want 2", n)
+	}
+}
+
+func TestTokenSourceRetriesRejectedToken(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	client := fake.APIClient()
+
+	if _, err := client.GetEventsByCampusID(ctx, 1); err != nil {
+		t.Fatalf("GetEventsByCampusID: %v", err)
+	}
+
+	fake.RevokeTokens()
+
+	events, err := client.GetEventsByCampusID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetEventsByCampusID with a revoked token: %v", err)
+	}
+
+	if len(events) != 2 {
+		t.Errorf("GetEventsByCampusID returned %d events, want 2", len(events))
+	}
+
+	if n := fake.Requests("/oauth/token"); n != 2 {
+		t.Errorf("requested %d tokens, want 2", n)
+	}
+
+	// One request for the first listing, then the rejected one and its retry.
+	if n := fake.Requests("/v2/campus/1/events"); n != 3 {
+		t.Errorf("sent %d requests for the events, want 3", n)
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// aead encrypts the intra tokens kept in sessions, see SetEncryptionKey.
+var aead cipher.AEAD
+
+// SetEncryptionKey sets the base64 encoded AES-256 key used to encrypt the
+// tokens at rest.
+func SetEncryptionKey(key string) error {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return fmt.Errorf("decode encryption key: %w", err)
+	}
+
+	if len(raw) != 32 {
+		return fmt.Errorf("encryption key must be 32 bytes, got %d", len(raw))
+	}
+
+	block, err := aes.NewCipher(raw)
+	if err != nil {
+		return err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	aead = gcm
+
+	return nil
+}
+
+// Encrypt seals plaintext with AES-GCM under a random nonce, returning both
+// encoded in base64.
+func Encrypt(plaintext string) (string, error) {
+	sealed, err := seal([]byte(plaintext))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt opens a value sealed by Encrypt.
+func Decrypt(ciphertext string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(sealed)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// seal encrypts plaintext under a random nonce, which it is prefixed with.
+func seal(plaintext []byte) ([]byte, error) {
+	if aead == nil {
+		return nil, errors.New("encryption key not set")
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open decrypts a value sealed by seal.
+func open(sealed []byte) ([]byte, error) {
+	if aead == nil {
+		return nil, errors.New("encryption key not set")
+	}
+
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	nonce, sealed := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	return aead.Open(nil, nonce, sealed, nil)
+}
+
+// NewSessionID returns a random, unguessable session ID.
+func NewSessionID() (string, error) {
+	return randomToken()
+}
+
+// NewRefreshToken returns a random refresh token. Only its hash, see
+// HashToken, is meant to be stored.
+func NewRefreshToken() (string, error) {
+	return randomToken()
+}
+
+// HashToken hashes a random token for storage. Tokens are unguessable, so a
+// plain SHA-256 is enough.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -1,44 +1,92 @@
 package auth
 
 import (
+	"context"
 	"errors"
-	"log"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// secret signs and verifies the JWTs, see SetSecret.
+var secret []byte
+
+// SetSecret sets the secret used to sign and verify the JWTs.
+func SetSecret(s string) {
+	secret = []byte(s)
+}
+
+// UserClaims identify the user and the session behind a JWT. The intra tokens
+// of the session stay on the server, see db.Session.
 type UserClaims struct {
-	UserID      int    `json:"user_id"`
-	AccessToken string `json:"access_token"`
+	UserID    int    `json:"user_id"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
+// Issue signs a JWT with the current key, naming it in the kid header, or
+// with the secret when no keys are used.
 func Issue(claims UserClaims) (string, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		log.Println("[WARN] JWT_SECRET not set, using default")
-		secret = "default"
+	keys.RLock()
+	defer keys.RUnlock()
+
+	if keys.store != nil {
+		key := currentKey(time.Now())
+		if key == nil {
+			return "", errors.New("no signing key")
+		}
+
+		accessToken := jwt.NewWithClaims(key.method, claims)
+		accessToken.Header["kid"] = key.id
+
+		return accessToken.SignedString(key.private)
+	}
+
+	if len(secret) == 0 {
+		return "", errors.New("JWT secret not set")
 	}
 
 	accessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
-	return accessToken.SignedString([]byte(secret))
+	return accessToken.SignedString(secret)
 }
 
-func Verify(token string) (*UserClaims, error) {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		log.Println("[WARN] JWT_SECRET not set, using default")
-		secret = "default"
-	}
+// Verify parses and checks a JWT, including that its session was not
+// revoked. When keys are used, the JWT must be signed by one of them that did
+// not expire, so that the JWTs signed before a rotation stay valid.
+func Verify(ctx context.Context, token string) (*UserClaims, error) {
+	keys.RLock()
+	useKeys := keys.store != nil
+	keys.RUnlock()
 
 	var claims UserClaims
+	var parsedToken *jwt.Token
+	var err error
 
-	parsedToken, err := jwt.ParseWithClaims(token, &claims, func(_ *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+	if useKeys {
+		parsedToken, err = jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+
+			key, err := findKey(ctx, kid)
+			if err != nil {
+				return nil, err
+			}
+
+			if t.Method.Alg() != key.method.Alg() {
+				return nil, errors.New("JWT algorithm does not match its key")
+			}
+
+			return key.public, nil
+		}, jwt.WithValidMethods([]string{RS256, EdDSA}))
+	} else {
+		if len(secret) == 0 {
+			return nil, errors.New("JWT secret not set")
+		}
+
+		parsedToken, err = jwt.ParseWithClaims(token, &claims, func(_ *jwt.Token) (interface{}, error) {
+			return secret, nil
+		}, jwt.WithValidMethods([]string{HS256}))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -51,5 +99,9 @@ func Verify(token string) (*UserClaims, error) {
 		return nil, errors.New("JWT expired")
 	}
 
+	if err := checkSession(ctx, claims.SessionID); err != nil {
+		return nil, err
+	}
+
 	return &claims, nil
 }
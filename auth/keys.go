@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/herbievine/42-events-api/db"
+)
+
+// The algorithms the JWTs can be signed with. HS256 uses the JWT secret, the
+// others rotating key pairs, see UseKeys.
+const (
+	HS256 string = "HS256"
+	RS256 string = "RS256"
+	EdDSA string = "EdDSA"
+)
+
+// keyCheckInterval is how often RunKeyRotation checks whether the signing key
+// is due for rotation, which also picks up the keys created by other
+// instances of the server.
+const keyCheckInterval = time.Minute
+
+// keyReloadInterval bounds how often a JWT signed by an unknown key reloads
+// the keys from the store.
+const keyReloadInterval = 10 * time.Second
+
+// keyRotationSlack is how long a key may keep signing after it was rotated,
+// until every instance of the server has picked up the new one.
+const keyRotationSlack = 5 * time.Minute
+
+type signingKey struct {
+	id        string
+	method    jwt.SigningMethod
+	public    crypto.PublicKey
+	createdAt time.Time
+	expiresAt time.Time
+
+	// private is nil when it could not be decrypted, such as after the
+	// encryption key changed. The key then only verifies.
+	private crypto.Signer
+}
+
+// keys holds the key pairs signing the JWTs, see UseKeys. Without them, the
+// JWTs are signed with the HS256 secret.
+var keys = struct {
+	sync.RWMutex
+	store     db.SigningKeyStore
+	algorithm string
+	rotation  time.Duration
+	lifetime  time.Duration
+	loadedAt  time.Time
+
+	// ring holds the keys that did not expire, newest first.
+	ring []signingKey
+}{}
+
+// UseKeys signs the JWTs with key pairs of algorithm, RS256 or EdDSA, kept in
+// store. Each key signs for rotation, after which a new one takes over, and
+// is kept to verify the JWTs it signed, valid for lifetime, until they have
+// all expired. The private keys are encrypted, so the encryption key must be
+// set first.
+func UseKeys(ctx context.Context, store db.SigningKeyStore, algorithm string, rotation time.Duration, lifetime time.Duration) error {
+	if algorithm != RS256 && algorithm != EdDSA {
+		return fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	keys.Lock()
+	keys.store = store
+	keys.algorithm = algorithm
+	keys.rotation = rotation
+	keys.lifetime = lifetime
+	keys.ring = nil
+	keys.Unlock()
+
+	return RotateKeys(ctx)
+}
+
+// RunKeyRotation rotates the keys in the background until ctx is done.
+func RunKeyRotation(ctx context.Context) {
+	ticker := time.NewTicker(keyCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := RotateKeys(ctx); err != nil {
+			log.Println("[WARN] Failed to rotate signing keys:", err)
+		}
+	}
+}
+
+// RotateKeys reloads the keys from the store, creates a new signing key if
+// the current one is due for rotation, and removes the expired ones.
+func RotateKeys(ctx context.Context) error {
+	if err := loadKeys(ctx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	keys.RLock()
+	current := currentKey(now)
+	store, algorithm, rotation, lifetime := keys.store, keys.algorithm, keys.rotation, keys.lifetime
+	keys.RUnlock()
+
+	if current == nil || now.Sub(current.createdAt) >= rotation {
+		key, err := newSigningKey(algorithm, now, now.Add(rotation+keyRotationSlack+lifetime))
+		if err != nil {
+			return err
+		}
+
+		if err := store.InsertOne(ctx, key); err != nil {
+			return err
+		}
+
+		log.Println("[INFO] Created", algorithm, "signing key", key.KeyID)
+
+		if err := loadKeys(ctx); err != nil {
+			return err
+		}
+	}
+
+	return store.DeleteExpired(ctx, now)
+}
+
+// loadKeys replaces the keys with the ones of the store.
+func loadKeys(ctx context.Context) error {
+	keys.RLock()
+	store := keys.store
+	keys.RUnlock()
+
+	stored, err := store.GetMany(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+
+	var ring []signingKey
+	for _, key := range stored {
+		if !now.Before(key.ExpiresAt) {
+			continue
+		}
+
+		parsed, err := parseSigningKey(key)
+		if err != nil {
+			log.Println("[WARN] Skipping signing key", key.KeyID, err)
+			continue
+		}
+
+		ring = append(ring, parsed)
+	}
+
+	keys.Lock()
+	keys.ring = ring
+	keys.loadedAt = now
+	keys.Unlock()
+
+	return nil
+}
+
+// currentKey returns the newest key that can sign, if any. A key no longer
+// signs once the JWTs it would sign would outlive it. The caller must hold
+// the lock.
+func currentKey(now time.Time) *signingKey {
+	for i := range keys.ring {
+		key := &keys.ring[i]
+
+		if key.private != nil && key.method.Alg() == keys.algorithm && now.Add(keys.lifetime).Before(key.expiresAt) {
+			return key
+		}
+	}
+
+	return nil
+}
+
+// findKey returns the key named kid, reloading the keys if it is unknown, as
+// it may have been created by another instance.
+func findKey(ctx context.Context, kid string) (*signingKey, error) {
+	lookup := func() *signingKey {
+		keys.RLock()
+		defer keys.RUnlock()
+
+		for i := range keys.ring {
+			if keys.ring[i].id == kid && time.Now().Before(keys.ring[i].expiresAt) {
+				key := keys.ring[i]
+				return &key
+			}
+		}
+
+		return nil
+	}
+
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+
+	keys.RLock()
+	reload := time.Since(keys.loadedAt) > keyReloadInterval
+	keys.RUnlock()
+
+	if reload {
+		if err := loadKeys(ctx); err != nil {
+			return nil, err
+		}
+
+		if key := lookup(); key != nil {
+			return key, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+func newSigningKey(algorithm string, createdAt time.Time, expiresAt time.Time) (db.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case RS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case EdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		err = fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+
+	if err != nil {
+		return db.SigningKey{}, err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return db.SigningKey{}, err
+	}
+
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return db.SigningKey{}, err
+	}
+
+	sealed, err := Encrypt(string(privateDER))
+	if err != nil {
+		return db.SigningKey{}, err
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return db.SigningKey{}, err
+	}
+
+	return db.SigningKey{
+		KeyID:      hex.EncodeToString(id),
+		Algorithm:  algorithm,
+		PrivateKey: sealed,
+		PublicKey:  base64.StdEncoding.EncodeToString(publicDER),
+		CreatedAt:  createdAt,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+func parseSigningKey(key db.SigningKey) (signingKey, error) {
+	parsed := signingKey{
+		id:        key.KeyID,
+		createdAt: key.CreatedAt,
+		expiresAt: key.ExpiresAt,
+	}
+
+	switch key.Algorithm {
+	case RS256:
+		parsed.method = jwt.SigningMethodRS256
+	case EdDSA:
+		parsed.method = jwt.SigningMethodEdDSA
+	default:
+		return parsed, fmt.Errorf("unsupported signing algorithm %q", key.Algorithm)
+	}
+
+	publicDER, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil {
+		return parsed, err
+	}
+
+	if parsed.public, err = x509.ParsePKIXPublicKey(publicDER); err != nil {
+		return parsed, err
+	}
+
+	privateDER, err := Decrypt(key.PrivateKey)
+	if err != nil {
+		log.Println("[WARN] Failed to decrypt signing key", key.KeyID, "using it to verify only:", err)
+		return parsed, nil
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey([]byte(privateDER))
+	if err != nil {
+		return parsed, err
+	}
+
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return parsed, errors.New("private key cannot sign")
+	}
+
+	parsed.private = signer
+
+	return parsed, nil
+}
+
+// JWK is a public key in the JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// N and E are the modulus and exponent of RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Curve and X are the curve and public key of EdDSA keys.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys verifying the JWTs. It is empty when they are
+// signed with the HS256 secret.
+func JWKS() JWKSet {
+	keys.RLock()
+	defer keys.RUnlock()
+
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range keys.ring {
+		if !time.Now().Before(key.expiresAt) {
+			continue
+		}
+
+		jwk := JWK{
+			KeyID:     key.id,
+			Use:       "sig",
+			Algorithm: key.method.Alg(),
+		}
+
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
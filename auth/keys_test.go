@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/herbievine/42-events-api/db"
+)
+
+// useKeys signs the JWTs with keys of algorithm kept in a new in-memory
+// store, which it returns, until the end of the test.
+func useKeys(t *testing.T, algorithm string) db.SigningKeyStore {
+	t.Helper()
+
+	if err := SetEncryptionKey(base64.StdEncoding.EncodeToString(make([]byte, 32))); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+
+	store := db.NewMemoryStore().SigningKeys()
+
+	if err := UseKeys(context.Background(), store, algorithm, time.Hour, 15*time.Minute); err != nil {
+		t.Fatalf("UseKeys: %v", err)
+	}
+
+	t.Cleanup(func() {
+		keys.Lock()
+		defer keys.Unlock()
+
+		keys.store = nil
+		keys.ring = nil
+	})
+
+	return store
+}
+
+// rotate creates a new signing key right away.
+func rotate(t *testing.T) {
+	t.Helper()
+
+	keys.Lock()
+	rotation := keys.rotation
+	keys.rotation = 0
+	keys.Unlock()
+
+	defer func() {
+		keys.Lock()
+		keys.rotation = rotation
+		keys.Unlock()
+	}()
+
+	if err := RotateKeys(context.Background()); err != nil {
+		t.Fatalf("RotateKeys: %v", err)
+	}
+}
+
+// kid returns the kid header of a JWT.
+func kid(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &UserClaims{})
+	if err != nil {
+		t.Fatalf("ParseUnverified: %v", err)
+	}
+
+	kid, _ := parsed.Header["kid"].(string)
+
+	return kid
+}
+
+func TestKeyRotation(t *testing.T) {
+	for _, algorithm := range []string{RS256, EdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			ctx := context.Background()
+
+			newSession(t, "session")
+			useKeys(t, algorithm)
+
+			before := issue(t, "session")
+
+			rotate(t)
+
+			after := issue(t, "session")
+
+			if kid(t, before) == kid(t, after) {
+				t.Fatalf("JWTs signed with the key %q before and after the rotation", kid(t, after))
+			}
+
+			if _, err := Verify(ctx, after); err != nil {
+				t.Errorf("Verify with the new key: %v", err)
+			}
+
+			if _, err := Verify(ctx, before); err != nil {
+				t.Errorf("Verify with the rotated key: %v", err)
+			}
+
+			if _, err := Verify(ctx, strings.Replace(after, ".", ".x", 1)); err == nil {
+				t.Errorf("Verify accepted a tampered JWT")
+			}
+		})
+	}
+}
+
+func TestVerifyReloadsUnknownKey(t *testing.T) {
+	ctx := context.Background()
+
+	newSession(t, "session")
+	store := useKeys(t, EdDSA)
+
+	// Another instance creates a key and signs with it.
+	now := time.Now()
+
+	stored, err := newSigningKey(EdDSA, now, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("newSigningKey: %v", err)
+	}
+
+	if err := store.InsertOne(ctx, stored); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+
+	key, err := parseSigningKey(stored)
+	if err != nil {
+		t.Fatalf("parseSigningKey: %v", err)
+	}
+
+	unsigned := jwt.NewWithClaims(key.method, claims("session"))
+	unsigned.Header["kid"] = key.id
+
+	token, err := unsigned.SignedString(key.private)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	// The keys were just loaded, so they are not reloaded yet.
+	if _, err := Verify(ctx, token); err == nil {
+		t.Errorf("Verify reloaded the keys within %v", keyReloadInterval)
+	}
+
+	keys.Lock()
+	keys.loadedAt = keys.loadedAt.Add(-keyReloadInterval - time.Second)
+	keys.Unlock()
+
+	if _, err := Verify(ctx, token); err != nil {
+		t.Errorf("Verify with a key of another instance: %v", err)
+	}
+
+	unsigned.Header["kid"] = "unknown"
+
+	token, err = unsigned.SignedString(key.private)
+	if err != nil {
+		t.Fatalf("SignedString: %v", err)
+	}
+
+	if _, err := Verify(ctx, token); err == nil {
+		t.Errorf("Verify accepted a JWT signed by an unknown key")
+	}
+}
+
+// publicKey returns the public key described by a JWK.
+func publicKey(t *testing.T, jwk JWK) crypto.PublicKey {
+	t.Helper()
+
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil {
+			t.Fatalf("decode JWK %s: %v", jwk.KeyID, err)
+		}
+
+		return b
+	}
+
+	switch jwk.KeyType {
+	case "RSA":
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decode(jwk.N)),
+			E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64()),
+		}
+	case "OKP":
+		if jwk.Curve != "Ed25519" {
+			t.Fatalf("JWK %s has the curve %q, want Ed25519", jwk.KeyID, jwk.Curve)
+		}
+
+		return ed25519.PublicKey(decode(jwk.X))
+	}
+
+	t.Fatalf("JWK %s has the key type %q", jwk.KeyID, jwk.KeyType)
+	return nil
+}
+
+func TestJWKS(t *testing.T) {
+	if set := JWKS(); len(set.Keys) != 0 {
+		t.Errorf("JWKS with the HS256 secret has %d keys, want 0", len(set.Keys))
+	}
+
+	for _, algorithm := range []string{RS256, EdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			newSession(t, "session")
+			useKeys(t, algorithm)
+
+			before := issue(t, "session")
+			rotate(t)
+			after := issue(t, "session")
+
+			set := JWKS()
+			if len(set.Keys) != 2 {
+				t.Fatalf("JWKS has %d keys, want 2", len(set.Keys))
+			}
+
+			public := make(map[string]crypto.PublicKey)
+			for _, jwk := range set.Keys {
+				if jwk.Algorithm != algorithm || jwk.Use != "sig" {
+					t.Errorf("JWK %s is for %q with %s, want sig with %s", jwk.KeyID, jwk.Use, jwk.Algorithm, algorithm)
+				}
+
+				public[jwk.KeyID] = publicKey(t, jwk)
+			}
+
+			// The JWKS alone verifies the JWTs, as other services do.
+			for _, token := range []string{before, after} {
+				_, err := jwt.ParseWithClaims(token, &UserClaims{}, func(token *jwt.Token) (interface{}, error) {
+					kid, _ := token.Header["kid"].(string)
+					return public[kid], nil
+				}, jwt.WithValidMethods([]string{algorithm}))
+				if err != nil {
+					t.Errorf("verify JWT with the JWKS: %v", err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+)
+
+// ErrSessionRevoked is returned by Verify for the JWT of a session that was
+// revoked, such as by a logout.
+var ErrSessionRevoked = errors.New("session revoked")
+
+// sessionCacheTTL is how long the state of a session is cached. It bounds
+// how long another instance of the server takes to notice a revocation.
+const sessionCacheTTL = 30 * time.Second
+
+// sessionCleanupInterval is how often RunSessionCleanup deletes the expired
+// sessions.
+const sessionCleanupInterval = time.Hour
+
+// maxCachedSessions bounds the cache, which is swept of expired entries when
+// it grows past this size.
+const maxCachedSessions = 10000
+
+type cachedSession struct {
+	familyID  string
+	revoked   bool
+	checkedAt time.Time
+}
+
+// sessions checks the sessions of the JWTs, see SetSessionStore.
+var sessions = struct {
+	sync.Mutex
+	store   db.SessionStore
+	entries map[string]cachedSession
+}{
+	entries: make(map[string]cachedSession),
+}
+
+// SetSessionStore sets the store Verify looks sessions up in.
+func SetSessionStore(store db.SessionStore) {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	sessions.store = store
+	sessions.entries = make(map[string]cachedSession)
+}
+
+// RunSessionCleanup deletes the sessions whose refresh token expired, every
+// sessionCleanupInterval until ctx is done.
+func RunSessionCleanup(ctx context.Context, store db.SessionStore) {
+	ticker := time.NewTicker(sessionCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := store.DeleteExpired(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Println("[WARN] Failed to delete expired sessions:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RevokeFamily marks the sessions of a family as revoked in the cache, once
+// they were revoked in the store, so that their JWTs are refused right away.
+func RevokeFamily(familyID string) {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	for id, entry := range sessions.entries {
+		if entry.familyID == familyID {
+			entry.revoked = true
+			sessions.entries[id] = entry
+		}
+	}
+}
+
+// checkSession returns ErrSessionRevoked if the session was revoked or does
+// not exist. Looking a session up is also when its last use is recorded, so
+// that it is written at most once per sessionCacheTTL.
+func checkSession(ctx context.Context, sessionID string) error {
+	sessions.Lock()
+	store := sessions.store
+	entry, ok := sessions.entries[sessionID]
+	sessions.Unlock()
+
+	if store == nil {
+		return errors.New("session store not set")
+	}
+
+	if !ok || time.Since(entry.checkedAt) > sessionCacheTTL {
+		session, err := store.GetOneByID(ctx, sessionID)
+		if err != nil && !errors.Is(err, db.ErrNotFound) {
+			return err
+		}
+
+		entry = cachedSession{revoked: true, checkedAt: time.Now()}
+
+		if session != nil {
+			entry.familyID = session.FamilyID
+			entry.revoked = !session.RevokedAt.IsZero()
+
+			if !entry.revoked {
+				if err := store.Touch(ctx, sessionID, entry.checkedAt); err != nil {
+					log.Println("[WARN] Failed to record use of session", sessionID, err)
+				}
+			}
+		}
+
+		cacheSession(sessionID, entry)
+	}
+
+	if entry.revoked {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+func cacheSession(sessionID string, entry cachedSession) {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	if len(sessions.entries) >= maxCachedSessions {
+		for id, cached := range sessions.entries {
+			if time.Since(cached.checkedAt) > sessionCacheTTL {
+				delete(sessions.entries, id)
+			}
+		}
+	}
+
+	sessions.entries[sessionID] = entry
+}
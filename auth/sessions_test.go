@@ -0,0 +1,121 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/herbievine/42-events-api/db"
+)
+
+// newSession stores a session of user 1001 in a new in-memory store used
+// by Verify, which it returns.
+func newSession(t *testing.T, sessionID string) db.SessionStore {
+	t.Helper()
+
+	SetSecret("test-secret")
+
+	store := db.NewMemoryStore().Sessions()
+	SetSessionStore(store)
+
+	now := time.Now()
+
+	err := store.InsertOne(context.Background(), db.Session{
+		SessionID:        sessionID,
+		FamilyID:         sessionID,
+		UserID:           1001,
+		RefreshHash:      HashToken(sessionID),
+		RefreshExpiresAt: now.Add(time.Hour),
+		CreatedAt:        now,
+		LastUsedAt:       now,
+	})
+	if err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+
+	return store
+}
+
+// claims returns the claims of a JWT for a session of user 1001.
+func claims(sessionID string) UserClaims {
+	return UserClaims{
+		UserID:    1001,
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+	}
+}
+
+// issue signs a JWT for a session of user 1001.
+func issue(t *testing.T, sessionID string) string {
+	t.Helper()
+
+	token, err := Issue(claims(sessionID))
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	return token
+}
+
+// expireCache makes the cached state of every session older than
+// sessionCacheTTL, as if the window had passed.
+func expireCache() {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	for id, entry := range sessions.entries {
+		entry.checkedAt = entry.checkedAt.Add(-sessionCacheTTL - time.Second)
+		sessions.entries[id] = entry
+	}
+}
+
+func TestVerifyRevokedByAnotherInstance(t *testing.T) {
+	ctx := context.Background()
+
+	store := newSession(t, "session")
+	token := issue(t, "session")
+
+	if _, err := Verify(ctx, token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	// Another instance revokes the session in the store only.
+	if err := store.RevokeFamily(ctx, "session", time.Now()); err != nil {
+		t.Fatalf("RevokeFamily: %v", err)
+	}
+
+	if _, err := Verify(ctx, token); err != nil {
+		t.Errorf("Verify within the cache window = %v, want the cached session accepted", err)
+	}
+
+	expireCache()
+
+	if _, err := Verify(ctx, token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Verify after the cache window = %v, want %v", err, ErrSessionRevoked)
+	}
+}
+
+func TestVerifyRevokedFamily(t *testing.T) {
+	ctx := context.Background()
+
+	store := newSession(t, "session")
+	token := issue(t, "session")
+
+	if _, err := Verify(ctx, token); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+
+	if err := store.RevokeFamily(ctx, "session", time.Now()); err != nil {
+		t.Fatalf("RevokeFamily: %v", err)
+	}
+
+	RevokeFamily("session")
+
+	if _, err := Verify(ctx, token); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Verify after RevokeFamily = %v, want %v", err, ErrSessionRevoked)
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// StateLifetime bounds how long a user may take to log in on the intra.
+const StateLifetime = 10 * time.Minute
+
+// State is the OAuth state of a login. It is sealed with the encryption key
+// before being sent to the intra, so that it can be neither forged nor read,
+// keeping the PKCE verifier secret. The sealed state is encoded in unpadded
+// URL-safe base64, so that the frontend can relay it in a query as is.
+type State struct {
+	// Nonce is also kept in a cookie of the browser that started the login,
+	// tying the callback to it.
+	Nonce string `json:"n"`
+
+	// Verifier is the PKCE code verifier, empty without PKCE.
+	Verifier string `json:"v,omitempty"`
+
+	ExpiresAt int64 `json:"e"`
+}
+
+// NewState creates the state of a login, with a PKCE verifier if pkce is
+// set, and returns it along with its sealed form.
+func NewState(pkce bool) (*State, string, error) {
+	nonce, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	state := State{
+		Nonce:     nonce,
+		ExpiresAt: time.Now().Add(StateLifetime).Unix(),
+	}
+
+	if pkce {
+		if state.Verifier, err = randomToken(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sealed, err := seal(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &state, base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// ParseState opens a state sealed by NewState, failing if it expired.
+func ParseState(sealed string) (*State, error) {
+	ciphertext, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, errors.New("state invalid")
+	}
+
+	data, err := open(ciphertext)
+	if err != nil {
+		return nil, errors.New("state invalid")
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, errors.New("state invalid")
+	}
+
+	if time.Now().Unix() > state.ExpiresAt {
+		return nil, errors.New("state expired")
+	}
+
+	return &state, nil
+}
+
+// Challenge returns the PKCE S256 challenge of the verifier, empty without
+// PKCE.
+func (s *State) Challenge() string {
+	if s.Verifier == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(s.Verifier))
+
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/syncer"
+)
+
+// runCommand runs a one-off command instead of the server:
+//
+//	sync              run a sync and print its result
+//	migrate           apply the pending database migrations
+//	migrate status    list the migrations and whether they were applied
+//
+// The config command, which prints the effective configuration with its
+// secrets redacted, is handled by main before connecting to the database.
+func runCommand(ctx context.Context, args []string, store db.Store, syncService *syncer.Service) error {
+	migrator, ok := store.(db.Migrator)
+	if !ok && args[0] == "migrate" {
+		return errors.New("the store does not support migrations")
+	}
+
+	switch {
+	case args[0] == "sync":
+		result, err := syncService.Run(ctx)
+		if result != nil {
+			json.NewEncoder(os.Stdout).Encode(result)
+		}
+
+		return err
+	case args[0] == "migrate" && len(args) == 1:
+		applied, err := migrator.Migrate(ctx)
+		fmt.Println("Applied", len(applied), "migrations")
+
+		return err
+	case args[0] == "migrate" && args[1] == "status":
+		applied, err := migrator.AppliedMigrations(ctx)
+		if err != nil {
+			return err
+		}
+
+		appliedAt := make(map[int]string, len(applied))
+		for _, migration := range applied {
+			appliedAt[migration.Version] = migration.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+
+		for _, migration := range migrator.Migrations() {
+			status, ok := appliedAt[migration.Version]
+			if !ok {
+				status = "pending"
+			}
+
+			fmt.Printf("%4d  %-20s  %s\n", migration.Version, status, migration.Name)
+		}
+
+		return nil
+	}
+
+	return errors.New("unknown command, expected one of: config, sync, migrate, migrate status")
+}
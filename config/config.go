@@ -0,0 +1,347 @@
+// Package config loads the settings of the server. Values come from, in order
+// of precedence, the environment, a .env file and an optional YAML file, on
+// top of defaults.
+package config
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/herbievine/42-events-api/api"
+	"github.com/herbievine/42-events-api/auth"
+	"github.com/herbievine/42-events-api/db"
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Development string = "development"
+	Production  string = "production"
+)
+
+// insecureSecret is the JWT secret used in development when none is set.
+const insecureSecret = "default"
+
+// insecureSessionKey is the session key used in development when none is set.
+const insecureSessionKey = "ZGVmYXVsdGRlZmF1bHRkZWZhdWx0ZGVmYXVsdGRlZmE="
+
+// minSecretLength is the length required of the JWT secret in production.
+const minSecretLength = 32
+
+const redacted = "[redacted]"
+
+type Config struct {
+	// Env is Development or Production.
+	Env  string `yaml:"env"`
+	Port int    `yaml:"port"`
+
+	// FrontendURL is the origin allowed by CORS and the base of the OAuth
+	// redirect URI.
+	FrontendURL string `yaml:"frontend_url"`
+
+	JWTSecret   string `yaml:"jwt_secret"`
+	AdminAPIKey string `yaml:"admin_api_key"`
+
+	// SessionKey is the base64 encoded AES-256 key encrypting the intra
+	// tokens kept in sessions.
+	SessionKey string `yaml:"session_key"`
+
+	// JWTLifetime is how long a JWT is valid, and RefreshLifetime how long a
+	// session lasts without being refreshed.
+	JWTLifetime     time.Duration `yaml:"jwt_lifetime"`
+	RefreshLifetime time.Duration `yaml:"refresh_lifetime"`
+
+	// JWTAlgorithm is HS256 to sign the JWTs with the JWT secret, or RS256 or
+	// EdDSA to sign them with key pairs replaced every JWTKeyRotation, whose
+	// public keys are served at /.well-known/jwks.json.
+	JWTAlgorithm   string        `yaml:"jwt_algorithm"`
+	JWTKeyRotation time.Duration `yaml:"jwt_key_rotation"`
+
+	// ShutdownTimeout bounds the draining of requests and of the sync.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+
+	DB   DB   `yaml:"db"`
+	API  API  `yaml:"api"`
+	Sync Sync `yaml:"sync"`
+}
+
+type DB struct {
+	URL         string        `yaml:"url"`
+	Timeout     time.Duration `yaml:"timeout"`
+	AutoMigrate bool          `yaml:"auto_migrate"`
+}
+
+type API struct {
+	URL          string        `yaml:"url"`
+	ClientID     string        `yaml:"client_id"`
+	ClientSecret string        `yaml:"client_secret"`
+	Timeout      time.Duration `yaml:"timeout"`
+
+	// PKCE adds a PKCE challenge to logins, for applications where the
+	// intra requires it.
+	PKCE bool `yaml:"pkce"`
+}
+
+type Sync struct {
+	// Interval between scheduled syncs, zero to only sync on demand.
+	Interval time.Duration `yaml:"interval"`
+	Jitter   time.Duration `yaml:"jitter"`
+	Workers  int           `yaml:"workers"`
+}
+
+// Default returns the configuration used for the values that are not set.
+func Default() Config {
+	return Config{
+		Env:             Development,
+		Port:            3000,
+		JWTLifetime:     15 * time.Minute,
+		RefreshLifetime: 30 * 24 * time.Hour,
+		JWTAlgorithm:    auth.HS256,
+		JWTKeyRotation:  30 * 24 * time.Hour,
+		ShutdownTimeout: 30 * time.Second,
+		DB: DB{
+			Timeout:     db.DefaultTimeout,
+			AutoMigrate: true,
+		},
+		API: API{
+			URL:     api.DefaultBaseURL,
+			Timeout: api.DefaultTimeout,
+		},
+		Sync: Sync{
+			Interval: time.Hour,
+			Jitter:   5 * time.Minute,
+			Workers:  4,
+		},
+	}
+}
+
+// Load reads the configuration and validates it. The YAML file is read from
+// CONFIG_FILE, or from config.yaml if it exists. A configuration that failed
+// validation is returned along with the error, so that it can be inspected.
+func Load() (*Config, error) {
+	godotenv.Load()
+
+	cfg := Default()
+
+	path, required := os.LookupEnv("CONFIG_FILE")
+	if !required {
+		path = "config.yaml"
+	}
+
+	if err := cfg.loadFile(path); err != nil && (required || !errors.Is(err, os.ErrNotExist)) {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+
+	if err := cfg.loadEnv(); err != nil {
+		return nil, err
+	}
+
+	if cfg.JWTSecret == "" && !cfg.IsProduction() {
+		log.Println("[WARN] JWT_SECRET not set, using an insecure default")
+
+		cfg.JWTSecret = insecureSecret
+	}
+
+	if cfg.SessionKey == "" && !cfg.IsProduction() {
+		log.Println("[WARN] SESSION_KEY not set, using an insecure default")
+
+		cfg.SessionKey = insecureSessionKey
+	}
+
+	return &cfg, cfg.Validate()
+}
+
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return yaml.Unmarshal(data, c)
+}
+
+// loadEnv overrides the configuration with the environment variables that
+// are set.
+func (c *Config) loadEnv() error {
+	var errs []error
+
+	str := func(key string, dst *string) {
+		if value, ok := os.LookupEnv(key); ok {
+			*dst = value
+		}
+	}
+
+	integer := func(key string, dst *int) {
+		if value, ok := os.LookupEnv(key); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+
+			*dst = n
+		}
+	}
+
+	boolean := func(key string, dst *bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+
+			*dst = b
+		}
+	}
+
+	duration := func(key string, dst *time.Duration) {
+		if value, ok := os.LookupEnv(key); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+				return
+			}
+
+			*dst = d
+		}
+	}
+
+	str("APP_ENV", &c.Env)
+	integer("PORT", &c.Port)
+	str("FRONTEND_URL", &c.FrontendURL)
+	str("JWT_SECRET", &c.JWTSecret)
+	str("ADMIN_API_KEY", &c.AdminAPIKey)
+	str("SESSION_KEY", &c.SessionKey)
+	duration("JWT_LIFETIME", &c.JWTLifetime)
+	duration("REFRESH_LIFETIME", &c.RefreshLifetime)
+	str("JWT_ALGORITHM", &c.JWTAlgorithm)
+	duration("JWT_KEY_ROTATION", &c.JWTKeyRotation)
+	duration("SHUTDOWN_TIMEOUT", &c.ShutdownTimeout)
+
+	str("DB_URL", &c.DB.URL)
+	duration("DB_TIMEOUT", &c.DB.Timeout)
+	boolean("DB_AUTO_MIGRATE", &c.DB.AutoMigrate)
+
+	str("FORTY_TWO_API_URL", &c.API.URL)
+	str("FORTY_TWO_API_CLIENT", &c.API.ClientID)
+	str("FORTY_TWO_API_SECRET", &c.API.ClientSecret)
+	duration("API_TIMEOUT", &c.API.Timeout)
+	boolean("FORTY_TWO_API_PKCE", &c.API.PKCE)
+
+	duration("SYNC_INTERVAL", &c.Sync.Interval)
+	duration("SYNC_JITTER", &c.Sync.Jitter)
+	integer("SYNC_WORKERS", &c.Sync.Workers)
+
+	return errors.Join(errs...)
+}
+
+// IsProduction reports whether the server runs in production.
+func (c *Config) IsProduction() bool {
+	return c.Env == Production
+}
+
+// Validate checks that the required values are set and sane. In production,
+// it refuses a JWT secret that is short or the insecure default when the JWTs
+// are signed with it, the insecure default session key and the in-memory
+// store.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Env != Development && c.Env != Production {
+		errs = append(errs, fmt.Errorf("APP_ENV must be %s or %s, got %q", Development, Production, c.Env))
+	}
+
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, fmt.Errorf("PORT must be between 1 and 65535, got %d", c.Port))
+	}
+
+	if c.FrontendURL == "" {
+		errs = append(errs, errors.New("FRONTEND_URL is required"))
+	}
+
+	if c.DB.URL == "" {
+		errs = append(errs, errors.New("DB_URL is required"))
+	} else if c.IsProduction() && c.DB.URL == db.MemoryURL {
+		errs = append(errs, errors.New("DB_URL must not be the in-memory store in production"))
+	}
+
+	if c.API.URL == "" {
+		errs = append(errs, errors.New("FORTY_TWO_API_URL must not be empty"))
+	}
+
+	if c.API.ClientID == "" || c.API.ClientSecret == "" {
+		errs = append(errs, errors.New("FORTY_TWO_API_CLIENT and FORTY_TWO_API_SECRET are required"))
+	}
+
+	if c.JWTLifetime <= 0 || c.RefreshLifetime <= 0 {
+		errs = append(errs, errors.New("JWT_LIFETIME and REFRESH_LIFETIME must be positive"))
+	}
+
+	switch c.JWTAlgorithm {
+	case auth.HS256, auth.RS256, auth.EdDSA:
+	default:
+		errs = append(errs, fmt.Errorf("JWT_ALGORITHM must be %s, %s or %s, got %q", auth.HS256, auth.RS256, auth.EdDSA, c.JWTAlgorithm))
+	}
+
+	if c.JWTKeyRotation <= 0 {
+		errs = append(errs, errors.New("JWT_KEY_ROTATION must be positive"))
+	}
+
+	if c.Sync.Workers < 1 {
+		errs = append(errs, fmt.Errorf("SYNC_WORKERS must be at least 1, got %d", c.Sync.Workers))
+	}
+
+	if c.IsProduction() && c.JWTAlgorithm == auth.HS256 && (c.JWTSecret == insecureSecret || len(c.JWTSecret) < minSecretLength) {
+		errs = append(errs, fmt.Errorf("JWT_SECRET must be set to at least %d characters in production", minSecretLength))
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(c.SessionKey); err != nil || len(key) != 32 {
+		errs = append(errs, errors.New("SESSION_KEY must be 32 bytes encoded in base64"))
+	} else if c.IsProduction() && c.SessionKey == insecureSessionKey {
+		errs = append(errs, errors.New("SESSION_KEY must not be the insecure default in production"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Redacted returns a copy of the configuration with its secrets hidden, safe
+// to log.
+func (c Config) Redacted() Config {
+	hide := func(secret string) string {
+		if secret == "" {
+			return ""
+		}
+
+		return redacted
+	}
+
+	c.JWTSecret = hide(c.JWTSecret)
+	c.AdminAPIKey = hide(c.AdminAPIKey)
+	c.SessionKey = hide(c.SessionKey)
+	c.API.ClientSecret = hide(c.API.ClientSecret)
+
+	// Database URLs may embed a password.
+	if u, err := url.Parse(c.DB.URL); err == nil {
+		c.DB.URL = u.Redacted()
+	} else {
+		c.DB.URL = hide(c.DB.URL)
+	}
+
+	return c
+}
+
+// Dump writes the redacted configuration to w as YAML.
+func (c Config) Dump(w io.Writer) error {
+	encoder := yaml.NewEncoder(w)
+	defer encoder.Close()
+
+	return encoder.Encode(c.Redacted())
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type Campus struct {
@@ -13,19 +12,26 @@ type Campus struct {
 	UserCount int    `json:"user_count" bson:"user_count"`
 	City      string `json:"city" bson:"city"`
 	Country   string `json:"country" bson:"country"`
+
+	// FetchAttendees makes the sync crawl the subscribers of every event of
+	// the campus instead of relying on the subscriber count of the intra.
+	FetchAttendees bool `json:"fetch_attendees" bson:"fetch_attendees,omitempty"`
 }
 
-func (coll *CampusCollection) GetMany() ([]Campus, error) {
+func (coll *CampusCollection) GetMany(ctx context.Context) ([]Campus, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
 	var campuses []Campus
 
-	cursor, err := coll.collection.Find(context.TODO(), bson.D{})
+	cursor, err := coll.collection.Find(ctx, bson.D{})
 	if err != nil {
 		return nil, err
 	}
 
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 		var campus Campus
 		err := cursor.Decode(&campus)
 		if err != nil {
@@ -38,27 +44,25 @@ func (coll *CampusCollection) GetMany() ([]Campus, error) {
 	return campuses, nil
 }
 
-func (coll *CampusCollection) GetOneByID(campusID int) (*Campus, error) {
+func (coll *CampusCollection) GetOneByID(ctx context.Context, campusID int) (*Campus, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
 	filter := bson.D{{Key: "campus_id", Value: campusID}}
 
 	var campus Campus
-	err := coll.collection.FindOne(context.TODO(), filter).Decode(&campus)
+	err := coll.collection.FindOne(ctx, filter).Decode(&campus)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
 	return &campus, nil
 }
 
-func (coll *CampusCollection) InsertOne(c Campus) (*mongo.InsertOneResult, error) {
-	return coll.collection.InsertOne(context.TODO(), c)
-}
-
-func (coll *CampusCollection) InsertMany(campuses []Campus) (*mongo.InsertManyResult, error) {
-	var docs []interface{}
-	for _, campus := range campuses {
-		docs = append(docs, campus)
-	}
+func (coll *CampusCollection) InsertOne(ctx context.Context, c Campus) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-	return coll.collection.InsertMany(context.TODO(), docs)
+	_, err := coll.collection.InsertOne(ctx, c)
+	return err
 }
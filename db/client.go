@@ -3,42 +3,71 @@ package db
 import (
 	"context"
 	"errors"
-	"os"
+	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Client is the MongoDB implementation of Store.
 type Client struct {
-	client *mongo.Client
+	client  *mongo.Client
+	timeout time.Duration
 }
 
+var (
+	_ Store    = (*Client)(nil)
+	_ Migrator = (*Client)(nil)
+)
+
 type UserCollection struct {
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
 type EventCollection struct {
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
 type CampusCollection struct {
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
 type NotificationCollection struct {
 	collection *mongo.Collection
+	timeout    time.Duration
 }
 
-func NewClient() (*Client, error) {
-	url := os.Getenv("DB_URL")
-	if url == "" {
-		return nil, errors.New("DB_URL is not set")
-	}
+type SyncRunCollection struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+type EventRevisionCollection struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+type SessionCollection struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
+
+type SigningKeyCollection struct {
+	collection *mongo.Collection
+	timeout    time.Duration
+}
 
+// NewClient connects to the MongoDB deployment at url, bounding each operation
+// by timeout.
+func NewClient(url string, timeout time.Duration) (*Client, error) {
 	serverAPI := options.ServerAPI(options.ServerAPIVersion1)
 	opts := options.Client().ApplyURI(url).SetServerAPIOptions(serverAPI)
 
-	ctx := context.TODO()
+	ctx, cancel := withTimeout(context.Background(), timeout)
+	defer cancel()
 
 	client, err := mongo.Connect(ctx, opts)
 	if err != nil {
@@ -49,25 +78,51 @@ func NewClient() (*Client, error) {
 		return nil, err
 	}
 
-	return &Client{client: client}, nil
+	return &Client{client: client, timeout: timeout}, nil
 }
 
 func (c *Client) Close(ctx context.Context) error {
 	return c.client.Disconnect(ctx)
 }
 
-func (c *Client) Users() *UserCollection {
-	return &UserCollection{c.client.Database("42-events").Collection("users")}
+func (c *Client) Users() UserStore {
+	return &UserCollection{c.client.Database("42-events").Collection("users"), c.timeout}
+}
+
+func (c *Client) Events() EventStore {
+	return &EventCollection{c.client.Database("42-events").Collection("events"), c.timeout}
+}
+
+func (c *Client) Campus() CampusStore {
+	return &CampusCollection{c.client.Database("42-events").Collection("campus"), c.timeout}
+}
+
+func (c *Client) Notifications() NotificationStore {
+	return &NotificationCollection{c.client.Database("42-events").Collection("notifications"), c.timeout}
 }
 
-func (c *Client) Events() *EventCollection {
-	return &EventCollection{c.client.Database("42-events").Collection("events")}
+func (c *Client) SyncRuns() SyncRunStore {
+	return &SyncRunCollection{c.client.Database("42-events").Collection("sync_runs"), c.timeout}
 }
 
-func (c *Client) Campus() *CampusCollection {
-	return &CampusCollection{c.client.Database("42-events").Collection("campus")}
+func (c *Client) EventRevisions() EventRevisionStore {
+	return &EventRevisionCollection{c.client.Database("42-events").Collection("event_revisions"), c.timeout}
 }
 
-func (c *Client) Notifications() *NotificationCollection {
-	return &NotificationCollection{c.client.Database("42-events").Collection("notifications")}
+func (c *Client) Sessions() SessionStore {
+	return &SessionCollection{c.client.Database("42-events").Collection("sessions"), c.timeout}
+}
+
+func (c *Client) SigningKeys() SigningKeyStore {
+	return &SigningKeyCollection{c.client.Database("42-events").Collection("signing_keys"), c.timeout}
+}
+
+// notFound translates the error of a lookup that matched no document to
+// ErrNotFound.
+func notFound(err error) error {
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return ErrNotFound
+	}
+
+	return err
 }
@@ -0,0 +1,51 @@
+package db_test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/db/storetest"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestClient runs the suite against the MongoDB deployment at TEST_DB_URL,
+// skipping it when unset. The deployment must be disposable: its 42-events
+// database is dropped before each test.
+func TestClient(t *testing.T) {
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	storetest.Run(t, func(t *testing.T) db.Store {
+		ctx := context.Background()
+
+		admin, err := mongo.Connect(ctx, options.Client().ApplyURI(url))
+		if err != nil {
+			t.Fatalf("Connect: %v", err)
+		}
+
+		defer admin.Disconnect(ctx)
+
+		if err := admin.Database("42-events").Drop(ctx); err != nil {
+			t.Fatalf("Drop: %v", err)
+		}
+
+		store, err := db.NewClient(url, 10*time.Second)
+		if err != nil {
+			t.Fatalf("NewClient: %v", err)
+		}
+
+		t.Cleanup(func() { store.Close(ctx) })
+
+		if _, err := store.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+
+		return store
+	})
+}
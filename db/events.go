@@ -5,8 +5,8 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type Event struct {
@@ -16,6 +16,7 @@ type Event struct {
 	Location     string    `json:"location" bson:"location,omitempty"`
 	Type         string    `json:"type" bson:"type,omitempty"`
 	Attendees    int       `json:"attendees" bson:"attendees"`
+	AttendeeIDs  []int     `json:"attendee_ids,omitempty" bson:"attendee_ids,omitempty"`
 	MaxAttendees int       `json:"max_attendees" bson:"max_attendees"`
 	BeginAt      time.Time `json:"begin_at" bson:"begin_at,omitempty"`
 	EndAt        time.Time `json:"end_at" bson:"end_at,omitempty"`
@@ -23,19 +24,21 @@ type Event struct {
 	CursusIDs    []int     `json:"cursus_ids" bson:"cursus_ids"`
 	CreatedAt    time.Time `json:"created_at" bson:"created_at,omitempty"`
 	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at,omitempty"`
+	Cancelled    bool      `json:"cancelled" bson:"cancelled,omitempty"`
+	CancelledAt  time.Time `json:"cancelled_at,omitempty" bson:"cancelled_at,omitempty"`
 }
 
-func (coll *EventCollection) GetMany(filter bson.D) ([]Event, error) {
+func (coll *EventCollection) getMany(ctx context.Context, filter bson.D) ([]Event, error) {
 	var events []Event
 
-	cursor, err := coll.collection.Find(context.TODO(), filter)
+	cursor, err := coll.collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 		var event Event
 		err := cursor.Decode(&event)
 		if err != nil {
@@ -48,7 +51,12 @@ func (coll *EventCollection) GetMany(filter bson.D) ([]Event, error) {
 	return events, nil
 }
 
-func (coll *EventCollection) GetManyByCampusID(campusID int) ([]Event, error) {
+// GetManyByCampusID returns the upcoming events of a campus, leaving out the
+// cancelled ones unless includeCancelled is set.
+func (coll *EventCollection) GetManyByCampusID(ctx context.Context, campusID int, includeCancelled bool) ([]Event, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
 	filter := bson.D{
 		{Key: "campus_ids", Value: campusID},
 		{Key: "begin_at", Value: bson.D{
@@ -56,53 +64,120 @@ func (coll *EventCollection) GetManyByCampusID(campusID int) ([]Event, error) {
 		}},
 	}
 
-	var events []Event
+	if !includeCancelled {
+		filter = append(filter, bson.E{Key: "cancelled", Value: bson.D{{Key: "$ne", Value: true}}})
+	}
+
+	return coll.getMany(ctx, filter)
+}
 
-	cursor, err := coll.collection.Find(context.TODO(), filter)
+func (coll *EventCollection) GetOneByID(ctx context.Context, eventID int) (*Event, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "event_id", Value: eventID}}
+
+	var event Event
+	err := coll.collection.FindOne(ctx, filter).Decode(&event)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
-	defer cursor.Close(context.TODO())
+	return &event, nil
+}
 
-	for cursor.Next(context.TODO()) {
-		var event Event
-		err := cursor.Decode(&event)
-		if err != nil {
-			return nil, err
-		}
+// GetManyByIDs returns the stored events among eventIDs.
+func (coll *EventCollection) GetManyByIDs(ctx context.Context, eventIDs []int) ([]Event, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-		events = append(events, event)
-	}
+	filter := bson.D{{Key: "event_id", Value: bson.D{{Key: "$in", Value: eventIDs}}}}
 
-	return events, nil
+	return coll.getMany(ctx, filter)
 }
 
-func (coll *EventCollection) GetOneByID(eventID int) (*Event, error) {
-	filter := bson.D{{Key: "event_id", Value: eventID}}
+// UpsertMany inserts or updates events by event ID in a single unordered bulk
+// write, restoring them if they were cancelled. The roster of an event is
+// only overwritten when set. It returns the IDs of the inserted events.
+func (coll *EventCollection) UpsertMany(ctx context.Context, events []Event) ([]int, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-	var event Event
-	err := coll.collection.FindOne(context.TODO(), filter).Decode(&event)
+	models := make([]mongo.WriteModel, 0, len(events))
+	for _, event := range events {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{{Key: "event_id", Value: event.EventID}}).
+			SetUpdate(upsertUpdate(event)).
+			SetUpsert(true))
+	}
+
+	opts := options.BulkWrite().SetOrdered(false)
+
+	res, err := coll.collection.BulkWrite(ctx, models, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &event, nil
+	inserted := make([]int, 0, len(res.UpsertedIDs))
+	for i := range res.UpsertedIDs {
+		inserted = append(inserted, events[i].EventID)
+	}
+
+	return inserted, nil
 }
 
-func (coll *EventCollection) InsertOne(e Event) (*mongo.InsertOneResult, error) {
-	return coll.collection.InsertOne(context.TODO(), e)
+// GetUpcoming returns the events of the given campuses that have not ended
+// nor been cancelled.
+func (coll *EventCollection) GetUpcoming(ctx context.Context, campusIDs []int) ([]Event, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "campus_ids", Value: bson.D{{Key: "$in", Value: campusIDs}}},
+		{Key: "end_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+		{Key: "cancelled", Value: bson.D{{Key: "$ne", Value: true}}},
+	}
+
+	return coll.getMany(ctx, filter)
 }
 
-func (coll *EventCollection) UpdateOneByFilter(filter primitive.D, update primitive.D) (*mongo.UpdateResult, error) {
-	return coll.collection.UpdateOne(context.TODO(), filter, update)
+func (coll *EventCollection) Cancel(ctx context.Context, eventID int, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "event_id", Value: eventID}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "cancelled", Value: true},
+		{Key: "cancelled_at", Value: at},
+	}}}
+
+	_, err := coll.collection.UpdateOne(ctx, filter, update)
+	return err
 }
 
-func (coll *EventCollection) InsertMany(events []Event) (*mongo.InsertManyResult, error) {
-	var docs []interface{}
-	for _, event := range events {
-		docs = append(docs, event)
+func upsertUpdate(event Event) bson.D {
+	set := bson.D{
+		{Key: "name", Value: event.Name},
+		{Key: "description", Value: event.Description},
+		{Key: "location", Value: event.Location},
+		{Key: "type", Value: event.Type},
+		{Key: "attendees", Value: event.Attendees},
+		{Key: "max_attendees", Value: event.MaxAttendees},
+		{Key: "begin_at", Value: event.BeginAt},
+		{Key: "end_at", Value: event.EndAt},
+		{Key: "campus_ids", Value: event.CampusIDs},
+		{Key: "cursus_ids", Value: event.CursusIDs},
+		{Key: "created_at", Value: event.CreatedAt},
+		{Key: "updated_at", Value: event.UpdatedAt},
+		{Key: "cancelled", Value: false},
 	}
 
-	return coll.collection.InsertMany(context.TODO(), docs)
+	if event.AttendeeIDs != nil {
+		set = append(set, bson.E{Key: "attendee_ids", Value: event.AttendeeIDs})
+	}
+
+	return bson.D{
+		{Key: "$set", Value: set},
+		{Key: "$unset", Value: bson.D{{Key: "cancelled_at", Value: ""}}},
+	}
 }
@@ -0,0 +1,555 @@
+package db
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is an in-memory Store for tests and local development. It is
+// safe for concurrent use and mirrors the behaviour of the MongoDB store.
+type MemoryStore struct {
+	mu            sync.RWMutex
+	users         []User
+	events        []Event
+	campuses      []Campus
+	notifications []Notification
+	syncRuns      []SyncRun
+	revisions     []EventRevision
+	sessions      []Session
+	signingKeys   []SigningKey
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (m *MemoryStore) Close(ctx context.Context) error {
+	return nil
+}
+
+func (m *MemoryStore) Users() UserStore {
+	return memoryUsers{m}
+}
+
+func (m *MemoryStore) Events() EventStore {
+	return memoryEvents{m}
+}
+
+func (m *MemoryStore) Campus() CampusStore {
+	return memoryCampus{m}
+}
+
+func (m *MemoryStore) Notifications() NotificationStore {
+	return memoryNotifications{m}
+}
+
+func (m *MemoryStore) SyncRuns() SyncRunStore {
+	return memorySyncRuns{m}
+}
+
+func (m *MemoryStore) EventRevisions() EventRevisionStore {
+	return memoryEventRevisions{m}
+}
+
+func (m *MemoryStore) Sessions() SessionStore {
+	return memorySessions{m}
+}
+
+func (m *MemoryStore) SigningKeys() SigningKeyStore {
+	return memorySigningKeys{m}
+}
+
+type memoryUsers struct {
+	*MemoryStore
+}
+
+func (m memoryUsers) GetOneByID(ctx context.Context, userID int) (*User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.UserID == userID {
+			return &user, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m memoryUsers) GetManyByCampusID(ctx context.Context, campusID int) ([]User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var users []User
+	for _, user := range m.users {
+		if slices.Contains(user.CampusIDs, campusID) {
+			users = append(users, user)
+		}
+	}
+
+	return users, nil
+}
+
+func (m memoryUsers) InsertOne(ctx context.Context, u User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.users = append(m.users, u)
+
+	return nil
+}
+
+type memoryEvents struct {
+	*MemoryStore
+}
+
+func (m memoryEvents) filter(keep func(Event) bool) []Event {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var events []Event
+	for _, event := range m.events {
+		if keep(event) {
+			events = append(events, event)
+		}
+	}
+
+	return events
+}
+
+func (m memoryEvents) GetOneByID(ctx context.Context, eventID int) (*Event, error) {
+	events := m.filter(func(e Event) bool { return e.EventID == eventID })
+	if len(events) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &events[0], nil
+}
+
+func (m memoryEvents) GetManyByIDs(ctx context.Context, eventIDs []int) ([]Event, error) {
+	return m.filter(func(e Event) bool { return slices.Contains(eventIDs, e.EventID) }), nil
+}
+
+func (m memoryEvents) GetManyByCampusID(ctx context.Context, campusID int, includeCancelled bool) ([]Event, error) {
+	now := time.Now()
+
+	return m.filter(func(e Event) bool {
+		return slices.Contains(e.CampusIDs, campusID) && !e.BeginAt.Before(now) && (includeCancelled || !e.Cancelled)
+	}), nil
+}
+
+func (m memoryEvents) GetUpcoming(ctx context.Context, campusIDs []int) ([]Event, error) {
+	now := time.Now()
+
+	return m.filter(func(e Event) bool {
+		return slices.ContainsFunc(e.CampusIDs, func(id int) bool { return slices.Contains(campusIDs, id) }) &&
+			e.EndAt.After(now) && !e.Cancelled
+	}), nil
+}
+
+func (m memoryEvents) UpsertMany(ctx context.Context, events []Event) ([]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var inserted []int
+
+	for _, event := range events {
+		i := slices.IndexFunc(m.events, func(e Event) bool { return e.EventID == event.EventID })
+		if i < 0 {
+			event.Cancelled = false
+			event.CancelledAt = time.Time{}
+
+			m.events = append(m.events, event)
+			inserted = append(inserted, event.EventID)
+			continue
+		}
+
+		if event.AttendeeIDs == nil {
+			event.AttendeeIDs = m.events[i].AttendeeIDs
+		}
+
+		event.Cancelled = false
+		event.CancelledAt = time.Time{}
+
+		m.events[i] = event
+	}
+
+	return inserted, nil
+}
+
+func (m memoryEvents) Cancel(ctx context.Context, eventID int, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.events {
+		if m.events[i].EventID == eventID {
+			m.events[i].Cancelled = true
+			m.events[i].CancelledAt = at
+		}
+	}
+
+	return nil
+}
+
+type memoryCampus struct {
+	*MemoryStore
+}
+
+func (m memoryCampus) GetMany(ctx context.Context) ([]Campus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return slices.Clone(m.campuses), nil
+}
+
+func (m memoryCampus) GetOneByID(ctx context.Context, campusID int) (*Campus, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, campus := range m.campuses {
+		if campus.CampusID == campusID {
+			return &campus, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m memoryCampus) InsertOne(ctx context.Context, c Campus) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.campuses = append(m.campuses, c)
+
+	return nil
+}
+
+type memoryNotifications struct {
+	*MemoryStore
+}
+
+func (m memoryNotifications) filter(keep func(Notification) bool) []Notification {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var notifications []Notification
+	for _, notification := range m.notifications {
+		if keep(notification) {
+			notifications = append(notifications, notification)
+		}
+	}
+
+	return notifications
+}
+
+func (m memoryNotifications) GetManyByUserID(ctx context.Context, userID int) ([]Notification, error) {
+	return m.filter(func(n Notification) bool { return n.UserID == userID }), nil
+}
+
+func (m memoryNotifications) GetManyByEventID(ctx context.Context, eventID int) ([]Notification, error) {
+	return m.filter(func(n Notification) bool { return n.EventID == eventID }), nil
+}
+
+func (m memoryNotifications) InsertMany(ctx context.Context, notifications []Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.notifications = append(m.notifications, notifications...)
+
+	return nil
+}
+
+func (m memoryNotifications) InsertManyIfAbsent(ctx context.Context, notifications []Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, notification := range notifications {
+		exists := slices.ContainsFunc(m.notifications, func(n Notification) bool {
+			return n.UserID == notification.UserID && n.EventID == notification.EventID && n.Kind == notification.Kind
+		})
+
+		if !exists {
+			m.notifications = append(m.notifications, notification)
+		}
+	}
+
+	return nil
+}
+
+func (m memoryNotifications) UpsertMany(ctx context.Context, notifications []Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, notification := range notifications {
+		i := slices.IndexFunc(m.notifications, func(n Notification) bool {
+			return n.UserID == notification.UserID && n.EventID == notification.EventID && n.Kind == notification.Kind
+		})
+
+		if i >= 0 {
+			m.notifications[i] = notification
+		} else {
+			m.notifications = append(m.notifications, notification)
+		}
+	}
+
+	return nil
+}
+
+func (m memoryNotifications) SetRead(ctx context.Context, userID int, eventID int, read bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.notifications {
+		if m.notifications[i].UserID == userID && m.notifications[i].EventID == eventID {
+			m.notifications[i].HasRead = read
+		}
+	}
+
+	return nil
+}
+
+type memorySyncRuns struct {
+	*MemoryStore
+}
+
+func (m memorySyncRuns) GetMany(ctx context.Context, limit int64) ([]SyncRun, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	runs := slices.Clone(m.syncRuns)
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].StartedAt.After(runs[j].StartedAt)
+	})
+
+	if int64(len(runs)) > limit {
+		runs = runs[:limit]
+	}
+
+	return runs, nil
+}
+
+func (m memorySyncRuns) GetOneByID(ctx context.Context, runID string) (*SyncRun, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, run := range m.syncRuns {
+		if run.RunID == runID {
+			return &run, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m memorySyncRuns) InsertOne(ctx context.Context, r SyncRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.syncRuns = append(m.syncRuns, r)
+
+	return nil
+}
+
+func (m memorySyncRuns) ReplaceOne(ctx context.Context, r SyncRun) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.syncRuns {
+		if m.syncRuns[i].RunID == r.RunID {
+			m.syncRuns[i] = r
+		}
+	}
+
+	return nil
+}
+
+type memoryEventRevisions struct {
+	*MemoryStore
+}
+
+func (m memoryEventRevisions) GetManyByEventID(ctx context.Context, eventID int) ([]EventRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var revisions []EventRevision
+	for _, revision := range m.revisions {
+		if revision.EventID == eventID {
+			revisions = append(revisions, revision)
+		}
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].CreatedAt.Before(revisions[j].CreatedAt)
+	})
+
+	return revisions, nil
+}
+
+func (m memoryEventRevisions) InsertOne(ctx context.Context, r EventRevision) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.revisions = append(m.revisions, r)
+
+	return nil
+}
+
+type memorySessions struct {
+	*MemoryStore
+}
+
+func (m memorySessions) GetOneByID(ctx context.Context, sessionID string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, session := range m.sessions {
+		if session.SessionID == sessionID {
+			return &session, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m memorySessions) GetActiveByUserID(ctx context.Context, userID int) ([]Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+
+	var sessions []Session
+	for _, session := range m.sessions {
+		if session.UserID == userID && session.RotatedAt.IsZero() && session.RevokedAt.IsZero() &&
+			session.RefreshExpiresAt.After(now) {
+			sessions = append(sessions, session)
+		}
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+
+	return sessions, nil
+}
+
+func (m memorySessions) InsertOne(ctx context.Context, s Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions = append(m.sessions, s)
+
+	return nil
+}
+
+func (m memorySessions) GetOneByRefreshHash(ctx context.Context, refreshHash string) (*Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, session := range m.sessions {
+		if session.RefreshHash == refreshHash {
+			return &session, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func (m memorySessions) Rotate(ctx context.Context, sessionID string, at time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.sessions {
+		session := &m.sessions[i]
+
+		if session.SessionID == sessionID && session.RotatedAt.IsZero() && session.RevokedAt.IsZero() {
+			session.RotatedAt = at
+			session.AccessToken = ""
+			session.RefreshToken = ""
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m memorySessions) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.sessions {
+		if m.sessions[i].FamilyID == familyID && m.sessions[i].RevokedAt.IsZero() {
+			m.sessions[i].RevokedAt = at
+			m.sessions[i].AccessToken = ""
+			m.sessions[i].RefreshToken = ""
+		}
+	}
+
+	return nil
+}
+
+func (m memorySessions) Touch(ctx context.Context, sessionID string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.sessions {
+		if m.sessions[i].SessionID == sessionID {
+			m.sessions[i].LastUsedAt = at
+		}
+	}
+
+	return nil
+}
+
+func (m memorySessions) DeleteExpired(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sessions = slices.DeleteFunc(m.sessions, func(s Session) bool {
+		return !s.RefreshExpiresAt.IsZero() && s.RefreshExpiresAt.Before(before)
+	})
+
+	return nil
+}
+
+type memorySigningKeys struct {
+	*MemoryStore
+}
+
+func (m memorySigningKeys) GetMany(ctx context.Context) ([]SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := slices.Clone(m.signingKeys)
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (m memorySigningKeys) InsertOne(ctx context.Context, k SigningKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.signingKeys = append(m.signingKeys, k)
+
+	return nil
+}
+
+func (m memorySigningKeys) DeleteExpired(ctx context.Context, before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.signingKeys = slices.DeleteFunc(m.signingKeys, func(k SigningKey) bool {
+		return k.ExpiresAt.Before(before)
+	})
+
+	return nil
+}
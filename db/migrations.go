@@ -0,0 +1,348 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is a versioned change to the schema of a store.
+type Migration struct {
+	Version int    `json:"version"`
+	Name    string `json:"name"`
+}
+
+type AppliedMigration struct {
+	Version   int       `json:"version" bson:"version"`
+	Name      string    `json:"name" bson:"name"`
+	AppliedAt time.Time `json:"applied_at" bson:"applied_at"`
+}
+
+// Migrator is implemented by the stores whose schema is versioned.
+type Migrator interface {
+	// Migrate applies the migrations that were not applied yet, in order,
+	// and returns them.
+	Migrate(ctx context.Context) ([]Migration, error)
+
+	// AppliedMigrations returns the migrations applied so far.
+	AppliedMigrations(ctx context.Context) ([]AppliedMigration, error)
+
+	// Migrations returns every known migration, applied or not.
+	Migrations() []Migration
+}
+
+// mongoMigration is a change to the MongoDB database, such as creating
+// indexes or rewriting documents. Up must be safe to run again if the process
+// stops before the migration is recorded.
+type mongoMigration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, database *mongo.Database) error
+}
+
+// mongoMigrations are applied in order of version. Never edit or remove a
+// migration that was released, add a new one instead.
+var mongoMigrations = []mongoMigration{
+	{
+		Version: 1,
+		Name:    "create schema_migrations index",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("schema_migrations"),
+				mongo.IndexModel{Keys: bson.D{{Key: "version", Value: 1}}, Options: options.Index().SetUnique(true)},
+			)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "remove duplicate events and make event_id unique",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			events := database.Collection("events")
+
+			if err := removeDuplicates(ctx, events, "event_id"); err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, events,
+				mongo.IndexModel{Keys: bson.D{{Key: "event_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "campus_ids", Value: 1}, {Key: "begin_at", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "end_at", Value: 1}}},
+			)
+		},
+	},
+	{
+		Version: 3,
+		Name:    "index notifications",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("notifications"),
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "event_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "event_id", Value: 1}}},
+			)
+		},
+	},
+	{
+		Version: 4,
+		Name:    "set the kind of notifications created before kinds",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			filter := bson.D{{Key: "kind", Value: bson.D{{Key: "$exists", Value: false}}}}
+			update := bson.D{{Key: "$set", Value: bson.D{{Key: "kind", Value: NotificationNewEvent}}}}
+
+			_, err := database.Collection("notifications").UpdateMany(ctx, filter, update)
+			return err
+		},
+	},
+	{
+		Version: 5,
+		Name:    "make user_id and campus_id unique",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			users := database.Collection("users")
+			campus := database.Collection("campus")
+
+			if err := removeDuplicates(ctx, users, "user_id"); err != nil {
+				return err
+			}
+
+			if err := removeDuplicates(ctx, campus, "campus_id"); err != nil {
+				return err
+			}
+
+			err := createIndexes(ctx, users,
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "campus_ids", Value: 1}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, campus,
+				mongo.IndexModel{Keys: bson.D{{Key: "campus_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+			)
+		},
+	},
+	{
+		Version: 6,
+		Name:    "index sync runs and event revisions",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			err := createIndexes(ctx, database.Collection("sync_runs"),
+				mongo.IndexModel{Keys: bson.D{{Key: "run_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "started_at", Value: -1}}},
+			)
+			if err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, database.Collection("event_revisions"),
+				mongo.IndexModel{Keys: bson.D{{Key: "event_id", Value: 1}, {Key: "created_at", Value: 1}}},
+			)
+		},
+	},
+	{
+		Version: 7,
+		Name:    "index sessions",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("sessions"),
+				mongo.IndexModel{Keys: bson.D{{Key: "session_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "user_id", Value: 1}}},
+			)
+		},
+	},
+	{
+		Version: 8,
+		Name:    "add session families and index refresh tokens",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			sessions := database.Collection("sessions")
+
+			filter := bson.D{{Key: "family_id", Value: bson.D{{Key: "$exists", Value: false}}}}
+			update := mongo.Pipeline{{{Key: "$set", Value: bson.D{{Key: "family_id", Value: "$session_id"}}}}}
+
+			if _, err := sessions.UpdateMany(ctx, filter, update); err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, sessions,
+				mongo.IndexModel{Keys: bson.D{{Key: "family_id", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "refresh_hash", Value: 1}}},
+				mongo.IndexModel{Keys: bson.D{{Key: "refresh_expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			)
+		},
+	},
+	{
+		Version: 9,
+		Name:    "set the start and last use of existing sessions",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			filter := bson.D{{Key: "started_at", Value: bson.D{{Key: "$exists", Value: false}}}}
+			update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+				{Key: "started_at", Value: "$created_at"},
+				{Key: "last_used_at", Value: "$created_at"},
+			}}}}
+
+			_, err := database.Collection("sessions").UpdateMany(ctx, filter, update)
+			return err
+		},
+	},
+	{
+		Version: 10,
+		Name:    "index signing keys",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			return createIndexes(ctx, database.Collection("signing_keys"),
+				mongo.IndexModel{Keys: bson.D{{Key: "key_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				mongo.IndexModel{Keys: bson.D{{Key: "created_at", Value: -1}}},
+			)
+		},
+	},
+	{
+		Version: 11,
+		Name:    "remove duplicate notifications and make them unique per user, event and kind",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			notifications := database.Collection("notifications")
+
+			if err := removeDuplicates(ctx, notifications, "user_id", "event_id", "kind"); err != nil {
+				return err
+			}
+
+			return createIndexes(ctx, notifications,
+				mongo.IndexModel{
+					Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "event_id", Value: 1}, {Key: "kind", Value: 1}},
+					Options: options.Index().SetUnique(true),
+				},
+			)
+		},
+	},
+}
+
+// Migrate applies the migrations that were not applied yet, in order, and
+// returns them.
+func (c *Client) Migrate(ctx context.Context) ([]Migration, error) {
+	applied, err := c.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, migration := range applied {
+		done[migration.Version] = true
+	}
+
+	database := c.client.Database("42-events")
+	collection := database.Collection("schema_migrations")
+
+	var ran []Migration
+
+	for _, migration := range mongoMigrations {
+		if done[migration.Version] {
+			continue
+		}
+
+		log.Printf("[INFO] Applying migration %d: %s", migration.Version, migration.Name)
+
+		if err := migration.Up(ctx, database); err != nil {
+			return ran, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		_, err := collection.InsertOne(ctx, AppliedMigration{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			AppliedAt: time.Now(),
+		})
+		if err != nil && !mongo.IsDuplicateKeyError(err) {
+			return ran, fmt.Errorf("record migration %d: %w", migration.Version, err)
+		}
+
+		ran = append(ran, Migration{Version: migration.Version, Name: migration.Name})
+	}
+
+	return ran, nil
+}
+
+// AppliedMigrations returns the migrations recorded in schema_migrations.
+func (c *Client) AppliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: 1}})
+
+	var applied []AppliedMigration
+
+	cursor, err := c.client.Database("42-events").Collection("schema_migrations").Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var migration AppliedMigration
+		err := cursor.Decode(&migration)
+		if err != nil {
+			return nil, err
+		}
+
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
+
+// Migrations returns every known migration, applied or not.
+func (c *Client) Migrations() []Migration {
+	known := make([]Migration, 0, len(mongoMigrations))
+	for _, migration := range mongoMigrations {
+		known = append(known, Migration{Version: migration.Version, Name: migration.Name})
+	}
+
+	return known
+}
+
+func createIndexes(ctx context.Context, collection *mongo.Collection, indexes ...mongo.IndexModel) error {
+	_, err := collection.Indexes().CreateMany(ctx, indexes)
+	return err
+}
+
+// removeDuplicates keeps a single document, the most recently inserted one,
+// for every combination of values of keys.
+func removeDuplicates(ctx context.Context, collection *mongo.Collection, keys ...string) error {
+	group := bson.D{}
+	for _, key := range keys {
+		group = append(group, bson.E{Key: key, Value: "$" + key})
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "_id", Value: -1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: group},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs []interface{} `bson:"ids"`
+		}
+
+		if err := cursor.Decode(&group); err != nil {
+			return err
+		}
+
+		filter := bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: group.IDs[1:]}}}}
+
+		res, err := collection.DeleteMany(ctx, filter)
+		if err != nil {
+			return err
+		}
+
+		log.Println("[INFO] Removed", res.DeletedCount, "duplicates from", collection.Name())
+	}
+
+	return cursor.Err()
+}
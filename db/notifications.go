@@ -5,29 +5,38 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Notification kinds. Notifications stored before kinds existed have none
+// and are new event notifications.
+const (
+	NotificationNewEvent       string = "new_event"
+	NotificationEventUpdated   string = "event_updated"
+	NotificationEventCancelled string = "event_cancelled"
 )
 
 type Notification struct {
 	UserID    int       `json:"user_id" bson:"user_id"`
 	EventID   int       `json:"event_id" bson:"event_id"`
+	Kind      string    `json:"kind" bson:"kind,omitempty"`
 	HasRead   bool      `json:"has_read" bson:"has_read"`
 	CreatedAt time.Time `json:"created_at" bson:"created_at"`
 	DeletedAt time.Time `json:"deleted_at" bson:"deleted_at,omitempty"`
 }
 
-func (coll *NotificationCollection) GetMany(filter bson.D) ([]Notification, error) {
+func (coll *NotificationCollection) getMany(ctx context.Context, filter bson.D) ([]Notification, error) {
 	var notifications []Notification
 
-	cursor, err := coll.collection.Find(context.TODO(), filter)
+	cursor, err := coll.collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 		var Notification Notification
 		err := cursor.Decode(&Notification)
 		if err != nil {
@@ -40,59 +49,93 @@ func (coll *NotificationCollection) GetMany(filter bson.D) ([]Notification, erro
 	return notifications, nil
 }
 
-func (coll *NotificationCollection) GetManyByCampusID(campusID int) ([]Notification, error) {
-	filter := bson.D{
-		{Key: "campus_ids", Value: campusID},
-		{Key: "begin_at", Value: bson.D{
-			{Key: "$gte", Value: time.Now()},
-		}},
-	}
+func (coll *NotificationCollection) GetManyByUserID(ctx context.Context, userID int) ([]Notification, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-	var notifications []Notification
+	return coll.getMany(ctx, bson.D{{Key: "user_id", Value: userID}})
+}
 
-	cursor, err := coll.collection.Find(context.TODO(), filter)
-	if err != nil {
-		return nil, err
-	}
+func (coll *NotificationCollection) GetManyByEventID(ctx context.Context, eventID int) ([]Notification, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-	defer cursor.Close(context.TODO())
+	return coll.getMany(ctx, bson.D{{Key: "event_id", Value: eventID}})
+}
 
-	for cursor.Next(context.TODO()) {
-		var Notification Notification
-		err := cursor.Decode(&Notification)
-		if err != nil {
-			return nil, err
-		}
+// SetRead marks every notification of a user about an event as read or
+// unread.
+func (coll *NotificationCollection) SetRead(ctx context.Context, userID int, eventID int, read bool) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-		notifications = append(notifications, Notification)
+	filter := bson.D{
+		{Key: "event_id", Value: eventID},
+		{Key: "user_id", Value: userID},
 	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "has_read", Value: read}}}}
 
-	return notifications, nil
+	_, err := coll.collection.UpdateMany(ctx, filter, update)
+	return err
 }
 
-func (coll *NotificationCollection) UpdateOneByFilter(filter primitive.D, update primitive.D) (*mongo.UpdateResult, error) {
-	return coll.collection.UpdateOne(context.TODO(), filter, update)
-}
+func (coll *NotificationCollection) InsertMany(ctx context.Context, notifications []Notification) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-func (coll *NotificationCollection) GetOneByFilter(filter primitive.D) (*Notification, error) {
-	var Notification Notification
-	err := coll.collection.FindOne(context.TODO(), filter).Decode(&Notification)
-	if err != nil {
-		return nil, err
+	var docs []interface{}
+	for _, Notification := range notifications {
+		docs = append(docs, Notification)
 	}
 
-	return &Notification, nil
+	_, err := coll.collection.InsertMany(ctx, docs)
+	return err
 }
 
-func (coll *NotificationCollection) InsertOne(e Notification) (*mongo.InsertOneResult, error) {
-	return coll.collection.InsertOne(context.TODO(), e)
+func (coll *NotificationCollection) InsertManyIfAbsent(ctx context.Context, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(notifications))
+	for _, notification := range notifications {
+		models = append(models, mongo.NewUpdateOneModel().
+			SetFilter(bson.D{
+				{Key: "user_id", Value: notification.UserID},
+				{Key: "event_id", Value: notification.EventID},
+				{Key: "kind", Value: notification.Kind},
+			}).
+			SetUpdate(bson.D{{Key: "$setOnInsert", Value: notification}}).
+			SetUpsert(true))
+	}
+
+	_, err := coll.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
 }
 
-func (coll *NotificationCollection) InsertMany(notifications []Notification) (*mongo.InsertManyResult, error) {
-	var docs []interface{}
-	for _, Notification := range notifications {
-		docs = append(docs, Notification)
+func (coll *NotificationCollection) UpsertMany(ctx context.Context, notifications []Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	models := make([]mongo.WriteModel, 0, len(notifications))
+	for _, notification := range notifications {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.D{
+				{Key: "user_id", Value: notification.UserID},
+				{Key: "event_id", Value: notification.EventID},
+				{Key: "kind", Value: notification.Kind},
+			}).
+			SetReplacement(notification).
+			SetUpsert(true))
 	}
 
-	return coll.collection.InsertMany(context.TODO(), docs)
+	_, err := coll.collection.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	return err
 }
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type FieldChange struct {
+	Field string      `json:"field" bson:"field"`
+	From  interface{} `json:"from" bson:"from"`
+	To    interface{} `json:"to" bson:"to"`
+}
+
+type EventRevision struct {
+	EventID   int           `json:"event_id" bson:"event_id"`
+	Changes   []FieldChange `json:"changes" bson:"changes"`
+	CreatedAt time.Time     `json:"created_at" bson:"created_at"`
+}
+
+func (coll *EventRevisionCollection) GetManyByEventID(ctx context.Context, eventID int) ([]EventRevision, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "event_id", Value: eventID}}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+
+	var revisions []EventRevision
+
+	cursor, err := coll.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var revision EventRevision
+		err := cursor.Decode(&revision)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+func (coll *EventRevisionCollection) InsertOne(ctx context.Context, r EventRevision) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	_, err := coll.collection.InsertOne(ctx, r)
+	return err
+}
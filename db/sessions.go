@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Session is a login of a user. It holds the intra tokens of the user, which
+// never leave the server, encrypted by the auth package.
+//
+// Refreshing a session rotates it: the session is marked as rotated and a new
+// one of the same family takes over, with a new refresh token. A family
+// starts with the session created on login and shares its ID. Rotated and
+// revoked sessions no longer hold the intra tokens, and sessions are deleted
+// once their refresh token expired.
+type Session struct {
+	SessionID    string `json:"session_id" bson:"session_id"`
+	FamilyID     string `json:"-" bson:"family_id"`
+	UserID       int    `json:"user_id" bson:"user_id"`
+	AccessToken  string `json:"-" bson:"access_token"`
+	RefreshToken string `json:"-" bson:"refresh_token"`
+
+	// ExpiresAt is when the intra access token expires.
+	ExpiresAt time.Time `json:"-" bson:"expires_at"`
+
+	// RefreshHash is the hash of the refresh token of the session, which
+	// can be used until RefreshExpiresAt.
+	RefreshHash      string    `json:"-" bson:"refresh_hash"`
+	RefreshExpiresAt time.Time `json:"-" bson:"refresh_expires_at"`
+
+	// UserAgent and IP describe the client the session was issued to.
+	UserAgent string `json:"user_agent" bson:"user_agent"`
+	IP        string `json:"ip" bson:"ip"`
+
+	// StartedAt is when the user logged in, shared by the whole family.
+	StartedAt  time.Time `json:"started_at" bson:"started_at"`
+	CreatedAt  time.Time `json:"created_at" bson:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at" bson:"last_used_at"`
+	RotatedAt  time.Time `json:"-" bson:"rotated_at,omitempty"`
+	RevokedAt  time.Time `json:"-" bson:"revoked_at,omitempty"`
+}
+
+func (coll *SessionCollection) GetOneByID(ctx context.Context, sessionID string) (*Session, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "session_id", Value: sessionID}}
+
+	var session Session
+	err := coll.collection.FindOne(ctx, filter).Decode(&session)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &session, nil
+}
+
+func (coll *SessionCollection) InsertOne(ctx context.Context, s Session) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	_, err := coll.collection.InsertOne(ctx, s)
+	return err
+}
+
+// GetActiveByUserID returns the sessions of a user that can still be
+// refreshed, the latest of each family, most recently used first.
+func (coll *SessionCollection) GetActiveByUserID(ctx context.Context, userID int) ([]Session, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "user_id", Value: userID},
+		{Key: "rotated_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "refresh_expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "last_used_at", Value: -1}})
+
+	var sessions []Session
+
+	cursor, err := coll.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var session Session
+		err := cursor.Decode(&session)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+func (coll *SessionCollection) GetOneByRefreshHash(ctx context.Context, refreshHash string) (*Session, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "refresh_hash", Value: refreshHash}}
+
+	var session Session
+	err := coll.collection.FindOne(ctx, filter).Decode(&session)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &session, nil
+}
+
+// Rotate marks the session as rotated and clears its intra tokens, reporting
+// false if it already was or if it was revoked. Only one of concurrent
+// rotations of a session succeeds.
+func (coll *SessionCollection) Rotate(ctx context.Context, sessionID string, at time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "session_id", Value: sessionID},
+		{Key: "rotated_at", Value: bson.D{{Key: "$exists", Value: false}}},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "rotated_at", Value: at},
+		{Key: "access_token", Value: ""},
+		{Key: "refresh_token", Value: ""},
+	}}}
+
+	result, err := coll.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+
+	return result.ModifiedCount == 1, nil
+}
+
+// RevokeFamily revokes every session of a family that was not revoked yet,
+// clearing their intra tokens.
+func (coll *SessionCollection) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{
+		{Key: "family_id", Value: familyID},
+		{Key: "revoked_at", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "revoked_at", Value: at},
+		{Key: "access_token", Value: ""},
+		{Key: "refresh_token", Value: ""},
+	}}}
+
+	_, err := coll.collection.UpdateMany(ctx, filter, update)
+	return err
+}
+
+// Touch records that the session was used at the given time.
+func (coll *SessionCollection) Touch(ctx context.Context, sessionID string, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "session_id", Value: sessionID}}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "last_used_at", Value: at}}}}
+
+	_, err := coll.collection.UpdateOne(ctx, filter, update)
+	return err
+}
+
+// DeleteExpired removes the sessions whose refresh token expired before the
+// given time. MongoDB also removes them on its own, see migration 8.
+func (coll *SessionCollection) DeleteExpired(ctx context.Context, before time.Time) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "refresh_expires_at", Value: bson.D{{Key: "$lt", Value: before}}}}
+
+	_, err := coll.collection.DeleteMany(ctx, filter)
+	return err
+}
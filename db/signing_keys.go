@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SigningKey is a key pair signing the JWTs, see the auth package. The
+// private key is encrypted, and the public one is served to the services
+// verifying the JWTs.
+type SigningKey struct {
+	KeyID      string `json:"kid" bson:"key_id"`
+	Algorithm  string `json:"alg" bson:"algorithm"`
+	PrivateKey string `json:"-" bson:"private_key"`
+	PublicKey  string `json:"public_key" bson:"public_key"`
+
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+
+	// ExpiresAt is when the JWTs signed with the key have all expired, and
+	// the key can be removed.
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// GetMany returns every key, newest first.
+func (coll *SigningKeyCollection) GetMany(ctx context.Context) ([]SigningKey, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	var keys []SigningKey
+
+	cursor, err := coll.collection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var key SigningKey
+		err := cursor.Decode(&key)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (coll *SigningKeyCollection) InsertOne(ctx context.Context, k SigningKey) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	_, err := coll.collection.InsertOne(ctx, k)
+	return err
+}
+
+// DeleteExpired removes the keys that expired before the given time.
+func (coll *SigningKeyCollection) DeleteExpired(ctx context.Context, before time.Time) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "expires_at", Value: bson.D{{Key: "$lt", Value: before}}}}
+
+	_, err := coll.collection.DeleteMany(ctx, filter)
+	return err
+}
@@ -0,0 +1,852 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// SQLiteClient is the SQLite implementation of Store, for deployments that
+// do not run MongoDB. Lists such as campus IDs are stored as JSON and times
+// as Unix milliseconds, the precision MongoDB keeps.
+type SQLiteClient struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+var (
+	_ Store    = (*SQLiteClient)(nil)
+	_ Migrator = (*SQLiteClient)(nil)
+)
+
+type sqliteUsers struct {
+	*SQLiteClient
+}
+
+type sqliteEvents struct {
+	*SQLiteClient
+}
+
+type sqliteCampus struct {
+	*SQLiteClient
+}
+
+type sqliteNotifications struct {
+	*SQLiteClient
+}
+
+type sqliteSyncRuns struct {
+	*SQLiteClient
+}
+
+type sqliteEventRevisions struct {
+	*SQLiteClient
+}
+
+type sqliteSessions struct {
+	*SQLiteClient
+}
+
+type sqliteSigningKeys struct {
+	*SQLiteClient
+}
+
+// NewSQLiteClient opens the SQLite database at path, creating it if needed,
+// bounding each operation by timeout. Use ":memory:" for a database that
+// lives as long as the client.
+func NewSQLiteClient(path string, timeout time.Duration) (*SQLiteClient, error) {
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(wal)")
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite allows a single writer. Sharing one connection serializes the
+	// writes of concurrent syncs and keeps in-memory databases alive.
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteClient{db: db, timeout: timeout}, nil
+}
+
+func (c *SQLiteClient) Close(ctx context.Context) error {
+	return c.db.Close()
+}
+
+func (c *SQLiteClient) Users() UserStore {
+	return &sqliteUsers{c}
+}
+
+func (c *SQLiteClient) Events() EventStore {
+	return &sqliteEvents{c}
+}
+
+func (c *SQLiteClient) Campus() CampusStore {
+	return &sqliteCampus{c}
+}
+
+func (c *SQLiteClient) Notifications() NotificationStore {
+	return &sqliteNotifications{c}
+}
+
+func (c *SQLiteClient) SyncRuns() SyncRunStore {
+	return &sqliteSyncRuns{c}
+}
+
+func (c *SQLiteClient) EventRevisions() EventRevisionStore {
+	return &sqliteEventRevisions{c}
+}
+
+func (c *SQLiteClient) Sessions() SessionStore {
+	return &sqliteSessions{c}
+}
+
+func (c *SQLiteClient) SigningKeys() SigningKeyStore {
+	return &sqliteSigningKeys{c}
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// toMillis converts t to Unix milliseconds, storing the zero time as NULL.
+func toMillis(t time.Time) sql.NullInt64 {
+	if t.IsZero() {
+		return sql.NullInt64{}
+	}
+
+	return sql.NullInt64{Int64: t.UnixMilli(), Valid: true}
+}
+
+func fromMillis(ms sql.NullInt64) time.Time {
+	if !ms.Valid {
+		return time.Time{}
+	}
+
+	return time.UnixMilli(ms.Int64).UTC()
+}
+
+// toJSON encodes v for a JSON column, storing nil slices as NULL.
+func toJSON[T any](v []T) (sql.NullString, error) {
+	if v == nil {
+		return sql.NullString{}, nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+
+	return sql.NullString{String: string(b), Valid: true}, nil
+}
+
+func fromJSON[T any](s sql.NullString) ([]T, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+
+	var v []T
+	err := json.Unmarshal([]byte(s.String), &v)
+
+	return v, err
+}
+
+// queryAll runs a query and scans each row with scan.
+func queryAll[T any](ctx context.Context, db *sql.DB, scan func(scanner) (T, error), query string, args ...any) ([]T, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var items []T
+
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// queryOne runs a query and scans its first row, returning ErrNotFound when
+// there is none.
+func queryOne[T any](ctx context.Context, db *sql.DB, scan func(scanner) (T, error), query string, args ...any) (*T, error) {
+	item, err := scan(db.QueryRowContext(ctx, query, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+const userColumns = "user_id, login, image_url, campus_ids, primary_campus_id, role, last_seen, created_at"
+
+func scanUser(row scanner) (User, error) {
+	var user User
+	var campusIDs sql.NullString
+	var lastSeen, createdAt sql.NullInt64
+
+	err := row.Scan(&user.UserID, &user.Login, &user.ImageURL, &campusIDs, &user.PrimaryCampusID, &user.Role, &lastSeen, &createdAt)
+	if err != nil {
+		return user, err
+	}
+
+	user.CampusIDs, err = fromJSON[int](campusIDs)
+	user.LastSeen = fromMillis(lastSeen)
+	user.CreatedAt = fromMillis(createdAt)
+
+	return user, err
+}
+
+func (s *sqliteUsers) GetManyByCampusID(ctx context.Context, campusID int) ([]User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanUser, `SELECT `+userColumns+` FROM users
+		WHERE EXISTS (SELECT 1 FROM json_each(users.campus_ids) WHERE value = ?)
+		ORDER BY user_id`, campusID)
+}
+
+func (s *sqliteUsers) GetOneByID(ctx context.Context, userID int) (*User, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryOne(ctx, s.db, scanUser, `SELECT `+userColumns+` FROM users WHERE user_id = ?`, userID)
+}
+
+func (s *sqliteUsers) InsertOne(ctx context.Context, u User) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	campusIDs, err := toJSON(u.CampusIDs)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO users (`+userColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		u.UserID, u.Login, u.ImageURL, campusIDs, u.PrimaryCampusID, u.Role, toMillis(u.LastSeen), toMillis(u.CreatedAt))
+
+	return err
+}
+
+const campusColumns = "campus_id, name, user_count, city, country, fetch_attendees"
+
+func scanCampus(row scanner) (Campus, error) {
+	var campus Campus
+
+	err := row.Scan(&campus.CampusID, &campus.Name, &campus.UserCount, &campus.City, &campus.Country, &campus.FetchAttendees)
+
+	return campus, err
+}
+
+func (s *sqliteCampus) GetMany(ctx context.Context) ([]Campus, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanCampus, `SELECT `+campusColumns+` FROM campus ORDER BY campus_id`)
+}
+
+func (s *sqliteCampus) GetOneByID(ctx context.Context, campusID int) (*Campus, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryOne(ctx, s.db, scanCampus, `SELECT `+campusColumns+` FROM campus WHERE campus_id = ?`, campusID)
+}
+
+func (s *sqliteCampus) InsertOne(ctx context.Context, c Campus) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO campus (`+campusColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+		c.CampusID, c.Name, c.UserCount, c.City, c.Country, c.FetchAttendees)
+
+	return err
+}
+
+const eventColumns = `event_id, name, description, location, type, attendees, attendee_ids, max_attendees,
+	begin_at, end_at, campus_ids, cursus_ids, created_at, updated_at, cancelled, cancelled_at`
+
+func scanEvent(row scanner) (Event, error) {
+	var event Event
+	var attendeeIDs, campusIDs, cursusIDs sql.NullString
+	var beginAt, endAt, createdAt, updatedAt, cancelledAt sql.NullInt64
+
+	err := row.Scan(&event.EventID, &event.Name, &event.Description, &event.Location, &event.Type,
+		&event.Attendees, &attendeeIDs, &event.MaxAttendees, &beginAt, &endAt, &campusIDs, &cursusIDs,
+		&createdAt, &updatedAt, &event.Cancelled, &cancelledAt)
+	if err != nil {
+		return event, err
+	}
+
+	if event.AttendeeIDs, err = fromJSON[int](attendeeIDs); err != nil {
+		return event, err
+	}
+
+	if event.CampusIDs, err = fromJSON[int](campusIDs); err != nil {
+		return event, err
+	}
+
+	if event.CursusIDs, err = fromJSON[int](cursusIDs); err != nil {
+		return event, err
+	}
+
+	event.BeginAt = fromMillis(beginAt)
+	event.EndAt = fromMillis(endAt)
+	event.CreatedAt = fromMillis(createdAt)
+	event.UpdatedAt = fromMillis(updatedAt)
+	event.CancelledAt = fromMillis(cancelledAt)
+
+	return event, nil
+}
+
+// GetManyByCampusID returns the upcoming events of a campus, leaving out the
+// cancelled ones unless includeCancelled is set.
+func (s *sqliteEvents) GetManyByCampusID(ctx context.Context, campusID int, includeCancelled bool) ([]Event, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanEvent, `SELECT `+eventColumns+` FROM events
+		WHERE EXISTS (SELECT 1 FROM json_each(events.campus_ids) WHERE value = ?)
+		AND begin_at >= ? AND (? OR NOT cancelled)
+		ORDER BY begin_at, event_id`, campusID, time.Now().UnixMilli(), includeCancelled)
+}
+
+func (s *sqliteEvents) GetOneByID(ctx context.Context, eventID int) (*Event, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryOne(ctx, s.db, scanEvent, `SELECT `+eventColumns+` FROM events WHERE event_id = ?`, eventID)
+}
+
+func (s *sqliteEvents) GetManyByIDs(ctx context.Context, eventIDs []int) ([]Event, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ids, err := json.Marshal(eventIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryAll(ctx, s.db, scanEvent, `SELECT `+eventColumns+` FROM events
+		WHERE event_id IN (SELECT value FROM json_each(?))
+		ORDER BY event_id`, string(ids))
+}
+
+// GetUpcoming returns the events of the given campuses that have not ended
+// nor been cancelled.
+func (s *sqliteEvents) GetUpcoming(ctx context.Context, campusIDs []int) ([]Event, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	ids, err := json.Marshal(campusIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryAll(ctx, s.db, scanEvent, `SELECT `+eventColumns+` FROM events
+		WHERE EXISTS (SELECT 1 FROM json_each(events.campus_ids) WHERE value IN (SELECT value FROM json_each(?)))
+		AND end_at > ? AND NOT cancelled
+		ORDER BY event_id`, string(ids), time.Now().UnixMilli())
+}
+
+// UpsertMany inserts the events that are not stored yet and replaces the
+// others, restoring them if they were cancelled. The attendee IDs of a stored
+// event are kept when the incoming event has none. It returns the IDs of the
+// inserted events.
+func (s *sqliteEvents) UpsertMany(ctx context.Context, events []Event) ([]int, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	var inserted []int
+
+	for _, event := range events {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM events WHERE event_id = ?)`, event.EventID).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+
+		attendeeIDs, err := toJSON(event.AttendeeIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		campusIDs, err := toJSON(event.CampusIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		cursusIDs, err := toJSON(event.CursusIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO events (`+eventColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, NULL)
+			ON CONFLICT (event_id) DO UPDATE SET
+				name = excluded.name,
+				description = excluded.description,
+				location = excluded.location,
+				type = excluded.type,
+				attendees = excluded.attendees,
+				attendee_ids = COALESCE(excluded.attendee_ids, events.attendee_ids),
+				max_attendees = excluded.max_attendees,
+				begin_at = excluded.begin_at,
+				end_at = excluded.end_at,
+				campus_ids = excluded.campus_ids,
+				cursus_ids = excluded.cursus_ids,
+				created_at = excluded.created_at,
+				updated_at = excluded.updated_at,
+				cancelled = 0,
+				cancelled_at = NULL`,
+			event.EventID, event.Name, event.Description, event.Location, event.Type, event.Attendees,
+			attendeeIDs, event.MaxAttendees, toMillis(event.BeginAt), toMillis(event.EndAt), campusIDs,
+			cursusIDs, toMillis(event.CreatedAt), toMillis(event.UpdatedAt))
+		if err != nil {
+			return nil, err
+		}
+
+		if !exists {
+			inserted = append(inserted, event.EventID)
+		}
+	}
+
+	return inserted, tx.Commit()
+}
+
+func (s *sqliteEvents) Cancel(ctx context.Context, eventID int, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE events SET cancelled = 1, cancelled_at = ? WHERE event_id = ?`,
+		toMillis(at), eventID)
+
+	return err
+}
+
+const notificationColumns = "user_id, event_id, kind, has_read, created_at, deleted_at"
+
+func scanNotification(row scanner) (Notification, error) {
+	var notification Notification
+	var createdAt, deletedAt sql.NullInt64
+
+	err := row.Scan(&notification.UserID, &notification.EventID, &notification.Kind, &notification.HasRead, &createdAt, &deletedAt)
+
+	notification.CreatedAt = fromMillis(createdAt)
+	notification.DeletedAt = fromMillis(deletedAt)
+
+	return notification, err
+}
+
+func (s *sqliteNotifications) GetManyByUserID(ctx context.Context, userID int) ([]Notification, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanNotification, `SELECT `+notificationColumns+` FROM notifications
+		WHERE user_id = ? ORDER BY id`, userID)
+}
+
+func (s *sqliteNotifications) GetManyByEventID(ctx context.Context, eventID int) ([]Notification, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanNotification, `SELECT `+notificationColumns+` FROM notifications
+		WHERE event_id = ? ORDER BY id`, eventID)
+}
+
+func (s *sqliteNotifications) SetRead(ctx context.Context, userID int, eventID int, read bool) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE notifications SET has_read = ? WHERE user_id = ? AND event_id = ?`,
+		read, userID, eventID)
+
+	return err
+}
+
+func (s *sqliteNotifications) InsertMany(ctx context.Context, notifications []Notification) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, n := range notifications {
+		_, err := tx.ExecContext(ctx, `INSERT INTO notifications (`+notificationColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+			n.UserID, n.EventID, n.Kind, n.HasRead, toMillis(n.CreatedAt), toMillis(n.DeletedAt))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteNotifications) InsertManyIfAbsent(ctx context.Context, notifications []Notification) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, n := range notifications {
+		_, err := tx.ExecContext(ctx, `INSERT INTO notifications (`+notificationColumns+`)
+			SELECT ?, ?, ?, ?, ?, ?
+			WHERE NOT EXISTS (SELECT 1 FROM notifications WHERE user_id = ? AND event_id = ? AND kind = ?)`,
+			n.UserID, n.EventID, n.Kind, n.HasRead, toMillis(n.CreatedAt), toMillis(n.DeletedAt),
+			n.UserID, n.EventID, n.Kind)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *sqliteNotifications) UpsertMany(ctx context.Context, notifications []Notification) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	for _, n := range notifications {
+		result, err := tx.ExecContext(ctx, `UPDATE notifications SET has_read = ?, created_at = ?, deleted_at = ?
+			WHERE user_id = ? AND event_id = ? AND kind = ?`,
+			n.HasRead, toMillis(n.CreatedAt), toMillis(n.DeletedAt), n.UserID, n.EventID, n.Kind)
+		if err != nil {
+			return err
+		}
+
+		updated, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if updated > 0 {
+			continue
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO notifications (`+notificationColumns+`) VALUES (?, ?, ?, ?, ?, ?)`,
+			n.UserID, n.EventID, n.Kind, n.HasRead, toMillis(n.CreatedAt), toMillis(n.DeletedAt))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+const syncRunColumns = `run_id, "trigger", status, started_at, finished_at, added, updated, restored, cancelled,
+	unchanged, skipped, failed, api_calls, retries, campuses, errors`
+
+func scanSyncRun(row scanner) (SyncRun, error) {
+	var run SyncRun
+	var startedAt, finishedAt sql.NullInt64
+	var campuses, errs sql.NullString
+
+	err := row.Scan(&run.RunID, &run.Trigger, &run.Status, &startedAt, &finishedAt, &run.Added, &run.Updated,
+		&run.Restored, &run.Cancelled, &run.Unchanged, &run.Skipped, &run.Failed, &run.APICalls, &run.Retries,
+		&campuses, &errs)
+	if err != nil {
+		return run, err
+	}
+
+	if run.Campuses, err = fromJSON[SyncRunCampus](campuses); err != nil {
+		return run, err
+	}
+
+	run.Errors, err = fromJSON[string](errs)
+	run.StartedAt = fromMillis(startedAt)
+	run.FinishedAt = fromMillis(finishedAt)
+
+	return run, err
+}
+
+// syncRunArgs returns the values of the sync run columns, in order.
+func syncRunArgs(r SyncRun) ([]any, error) {
+	campuses, err := toJSON(r.Campuses)
+	if err != nil {
+		return nil, err
+	}
+
+	errs, err := toJSON(r.Errors)
+	if err != nil {
+		return nil, err
+	}
+
+	return []any{r.RunID, r.Trigger, r.Status, toMillis(r.StartedAt), toMillis(r.FinishedAt), r.Added, r.Updated,
+		r.Restored, r.Cancelled, r.Unchanged, r.Skipped, r.Failed, r.APICalls, r.Retries, campuses, errs}, nil
+}
+
+// GetMany returns the latest sync runs, most recent first.
+func (s *sqliteSyncRuns) GetMany(ctx context.Context, limit int64) ([]SyncRun, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanSyncRun, `SELECT `+syncRunColumns+` FROM sync_runs
+		ORDER BY started_at DESC LIMIT ?`, limit)
+}
+
+func (s *sqliteSyncRuns) GetOneByID(ctx context.Context, runID string) (*SyncRun, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryOne(ctx, s.db, scanSyncRun, `SELECT `+syncRunColumns+` FROM sync_runs WHERE run_id = ?`, runID)
+}
+
+func (s *sqliteSyncRuns) InsertOne(ctx context.Context, r SyncRun) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	args, err := syncRunArgs(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO sync_runs (`+syncRunColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, args...)
+
+	return err
+}
+
+// ReplaceOne replaces the sync run with the same run ID.
+func (s *sqliteSyncRuns) ReplaceOne(ctx context.Context, r SyncRun) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	args, err := syncRunArgs(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `UPDATE sync_runs SET
+		"trigger" = ?, status = ?, started_at = ?, finished_at = ?, added = ?, updated = ?, restored = ?,
+		cancelled = ?, unchanged = ?, skipped = ?, failed = ?, api_calls = ?, retries = ?, campuses = ?, errors = ?
+		WHERE run_id = ?`, append(args[1:], r.RunID)...)
+
+	return err
+}
+
+func scanEventRevision(row scanner) (EventRevision, error) {
+	var revision EventRevision
+	var changes sql.NullString
+	var createdAt sql.NullInt64
+
+	err := row.Scan(&revision.EventID, &changes, &createdAt)
+	if err != nil {
+		return revision, err
+	}
+
+	revision.Changes, err = fromJSON[FieldChange](changes)
+	revision.CreatedAt = fromMillis(createdAt)
+
+	return revision, err
+}
+
+// GetManyByEventID returns the revisions of an event, oldest first.
+func (s *sqliteEventRevisions) GetManyByEventID(ctx context.Context, eventID int) ([]EventRevision, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanEventRevision, `SELECT event_id, changes, created_at FROM event_revisions
+		WHERE event_id = ? ORDER BY created_at, id`, eventID)
+}
+
+func (s *sqliteEventRevisions) InsertOne(ctx context.Context, r EventRevision) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	changes, err := toJSON(r.Changes)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO event_revisions (event_id, changes, created_at) VALUES (?, ?, ?)`,
+		r.EventID, changes, toMillis(r.CreatedAt))
+
+	return err
+}
+
+const sessionColumns = `session_id, family_id, user_id, access_token, refresh_token, expires_at, refresh_hash,
+	refresh_expires_at, user_agent, ip, started_at, created_at, last_used_at, rotated_at, revoked_at`
+
+func scanSession(row scanner) (Session, error) {
+	var session Session
+	var expiresAt, refreshExpiresAt, startedAt, createdAt, lastUsedAt, rotatedAt, revokedAt sql.NullInt64
+
+	err := row.Scan(&session.SessionID, &session.FamilyID, &session.UserID, &session.AccessToken, &session.RefreshToken,
+		&expiresAt, &session.RefreshHash, &refreshExpiresAt, &session.UserAgent, &session.IP, &startedAt, &createdAt,
+		&lastUsedAt, &rotatedAt, &revokedAt)
+
+	session.ExpiresAt = fromMillis(expiresAt)
+	session.RefreshExpiresAt = fromMillis(refreshExpiresAt)
+	session.StartedAt = fromMillis(startedAt)
+	session.CreatedAt = fromMillis(createdAt)
+	session.LastUsedAt = fromMillis(lastUsedAt)
+	session.RotatedAt = fromMillis(rotatedAt)
+	session.RevokedAt = fromMillis(revokedAt)
+
+	return session, err
+}
+
+func (s *sqliteSessions) GetOneByID(ctx context.Context, sessionID string) (*Session, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryOne(ctx, s.db, scanSession, `SELECT `+sessionColumns+` FROM sessions WHERE session_id = ?`, sessionID)
+}
+
+func (s *sqliteSessions) GetOneByRefreshHash(ctx context.Context, refreshHash string) (*Session, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryOne(ctx, s.db, scanSession, `SELECT `+sessionColumns+` FROM sessions WHERE refresh_hash = ?`, refreshHash)
+}
+
+// GetActiveByUserID returns the sessions of a user that can still be
+// refreshed, most recently used first.
+func (s *sqliteSessions) GetActiveByUserID(ctx context.Context, userID int) ([]Session, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanSession, `SELECT `+sessionColumns+` FROM sessions
+		WHERE user_id = ? AND rotated_at IS NULL AND revoked_at IS NULL AND refresh_expires_at > ?
+		ORDER BY last_used_at DESC, session_id`, userID, time.Now().UnixMilli())
+}
+
+func (s *sqliteSessions) InsertOne(ctx context.Context, session Session) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO sessions (`+sessionColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.SessionID, session.FamilyID, session.UserID, session.AccessToken, session.RefreshToken,
+		toMillis(session.ExpiresAt), session.RefreshHash, toMillis(session.RefreshExpiresAt), session.UserAgent,
+		session.IP, toMillis(session.StartedAt), toMillis(session.CreatedAt), toMillis(session.LastUsedAt),
+		toMillis(session.RotatedAt), toMillis(session.RevokedAt))
+
+	return err
+}
+
+// Rotate marks the session as rotated, reporting false if it already was or
+// if it was revoked.
+func (s *sqliteSessions) Rotate(ctx context.Context, sessionID string, at time.Time) (bool, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, `UPDATE sessions SET rotated_at = ?, access_token = '', refresh_token = ''
+		WHERE session_id = ? AND rotated_at IS NULL AND revoked_at IS NULL`, toMillis(at), sessionID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := result.RowsAffected()
+
+	return n == 1, err
+}
+
+func (s *sqliteSessions) RevokeFamily(ctx context.Context, familyID string, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE sessions SET revoked_at = ?, access_token = '', refresh_token = ''
+		WHERE family_id = ? AND revoked_at IS NULL`, toMillis(at), familyID)
+
+	return err
+}
+
+func (s *sqliteSessions) Touch(ctx context.Context, sessionID string, at time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE sessions SET last_used_at = ? WHERE session_id = ?`, toMillis(at), sessionID)
+
+	return err
+}
+
+func (s *sqliteSessions) DeleteExpired(ctx context.Context, before time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM sessions WHERE refresh_expires_at < ?`, toMillis(before))
+
+	return err
+}
+
+func scanSigningKey(row scanner) (SigningKey, error) {
+	var key SigningKey
+	var createdAt, expiresAt sql.NullInt64
+
+	err := row.Scan(&key.KeyID, &key.Algorithm, &key.PrivateKey, &key.PublicKey, &createdAt, &expiresAt)
+
+	key.CreatedAt = fromMillis(createdAt)
+	key.ExpiresAt = fromMillis(expiresAt)
+
+	return key, err
+}
+
+// GetMany returns every key, newest first.
+func (s *sqliteSigningKeys) GetMany(ctx context.Context) ([]SigningKey, error) {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	return queryAll(ctx, s.db, scanSigningKey, `SELECT key_id, algorithm, private_key, public_key, created_at, expires_at
+		FROM signing_keys ORDER BY created_at DESC, key_id`)
+}
+
+func (s *sqliteSigningKeys) InsertOne(ctx context.Context, k SigningKey) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `INSERT INTO signing_keys (key_id, algorithm, private_key, public_key, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, k.KeyID, k.Algorithm, k.PrivateKey, k.PublicKey, toMillis(k.CreatedAt), toMillis(k.ExpiresAt))
+
+	return err
+}
+
+func (s *sqliteSigningKeys) DeleteExpired(ctx context.Context, before time.Time) error {
+	ctx, cancel := withTimeout(ctx, s.timeout)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `DELETE FROM signing_keys WHERE expires_at < ?`, toMillis(before))
+
+	return err
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+)
+
+// sqliteMigration is a change to the SQLite schema. Its statements run in the
+// same transaction as the recording of the migration.
+type sqliteMigration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// sqliteMigrations are applied in order of version. Never edit or remove a
+// migration that was released, add a new one instead.
+var sqliteMigrations = []sqliteMigration{
+	{
+		Version: 1,
+		Name:    "create users, campus, events and notifications",
+		SQL: `
+			CREATE TABLE users (
+				user_id INTEGER PRIMARY KEY,
+				login TEXT NOT NULL,
+				image_url TEXT NOT NULL DEFAULT '',
+				campus_ids TEXT,
+				primary_campus_id INTEGER NOT NULL DEFAULT 0,
+				role TEXT NOT NULL DEFAULT '',
+				last_seen INTEGER,
+				created_at INTEGER
+			);
+
+			CREATE TABLE campus (
+				campus_id INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				user_count INTEGER NOT NULL DEFAULT 0,
+				city TEXT NOT NULL DEFAULT '',
+				country TEXT NOT NULL DEFAULT '',
+				fetch_attendees INTEGER NOT NULL DEFAULT 0
+			);
+
+			CREATE TABLE events (
+				event_id INTEGER PRIMARY KEY,
+				name TEXT NOT NULL,
+				description TEXT NOT NULL DEFAULT '',
+				location TEXT NOT NULL DEFAULT '',
+				type TEXT NOT NULL DEFAULT '',
+				attendees INTEGER NOT NULL DEFAULT 0,
+				attendee_ids TEXT,
+				max_attendees INTEGER NOT NULL DEFAULT 0,
+				begin_at INTEGER,
+				end_at INTEGER,
+				campus_ids TEXT,
+				cursus_ids TEXT,
+				created_at INTEGER,
+				updated_at INTEGER,
+				cancelled INTEGER NOT NULL DEFAULT 0,
+				cancelled_at INTEGER
+			);
+
+			CREATE INDEX events_begin_at ON events (begin_at);
+			CREATE INDEX events_end_at ON events (end_at);
+
+			CREATE TABLE notifications (
+				id INTEGER PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				event_id INTEGER NOT NULL,
+				kind TEXT NOT NULL DEFAULT '',
+				has_read INTEGER NOT NULL DEFAULT 0,
+				created_at INTEGER,
+				deleted_at INTEGER
+			);
+
+			CREATE INDEX notifications_user_id_event_id ON notifications (user_id, event_id);
+			CREATE INDEX notifications_event_id ON notifications (event_id);
+		`,
+	},
+	{
+		Version: 2,
+		Name:    "create sync runs and event revisions",
+		SQL: `
+			CREATE TABLE sync_runs (
+				run_id TEXT PRIMARY KEY,
+				"trigger" TEXT NOT NULL,
+				status TEXT NOT NULL,
+				started_at INTEGER,
+				finished_at INTEGER,
+				added INTEGER NOT NULL DEFAULT 0,
+				updated INTEGER NOT NULL DEFAULT 0,
+				restored INTEGER NOT NULL DEFAULT 0,
+				cancelled INTEGER NOT NULL DEFAULT 0,
+				unchanged INTEGER NOT NULL DEFAULT 0,
+				skipped INTEGER NOT NULL DEFAULT 0,
+				failed INTEGER NOT NULL DEFAULT 0,
+				api_calls INTEGER NOT NULL DEFAULT 0,
+				retries INTEGER NOT NULL DEFAULT 0,
+				campuses TEXT,
+				errors TEXT
+			);
+
+			CREATE INDEX sync_runs_started_at ON sync_runs (started_at);
+
+			CREATE TABLE event_revisions (
+				id INTEGER PRIMARY KEY,
+				event_id INTEGER NOT NULL,
+				changes TEXT,
+				created_at INTEGER
+			);
+
+			CREATE INDEX event_revisions_event_id_created_at ON event_revisions (event_id, created_at);
+		`,
+	},
+	{
+		Version: 3,
+		Name:    "create sessions",
+		SQL: `
+			CREATE TABLE sessions (
+				session_id TEXT PRIMARY KEY,
+				user_id INTEGER NOT NULL,
+				access_token TEXT NOT NULL,
+				refresh_token TEXT NOT NULL DEFAULT '',
+				expires_at INTEGER,
+				created_at INTEGER
+			);
+
+			CREATE INDEX sessions_user_id ON sessions (user_id);
+		`,
+	},
+	{
+		Version: 4,
+		Name:    "add session families and refresh tokens",
+		SQL: `
+			ALTER TABLE sessions ADD COLUMN family_id TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN refresh_hash TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN refresh_expires_at INTEGER;
+			ALTER TABLE sessions ADD COLUMN rotated_at INTEGER;
+			ALTER TABLE sessions ADD COLUMN revoked_at INTEGER;
+
+			UPDATE sessions SET family_id = session_id;
+
+			CREATE INDEX sessions_family_id ON sessions (family_id);
+			CREATE INDEX sessions_refresh_hash ON sessions (refresh_hash);
+			CREATE INDEX sessions_refresh_expires_at ON sessions (refresh_expires_at);
+		`,
+	},
+	{
+		Version: 5,
+		Name:    "add session clients and usage",
+		SQL: `
+			ALTER TABLE sessions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN ip TEXT NOT NULL DEFAULT '';
+			ALTER TABLE sessions ADD COLUMN started_at INTEGER;
+			ALTER TABLE sessions ADD COLUMN last_used_at INTEGER;
+
+			UPDATE sessions SET started_at = created_at, last_used_at = created_at;
+		`,
+	},
+	{
+		Version: 6,
+		Name:    "create signing keys",
+		SQL: `
+			CREATE TABLE signing_keys (
+				key_id TEXT PRIMARY KEY,
+				algorithm TEXT NOT NULL,
+				private_key TEXT NOT NULL,
+				public_key TEXT NOT NULL,
+				created_at INTEGER,
+				expires_at INTEGER
+			);
+
+			CREATE INDEX signing_keys_created_at ON signing_keys (created_at);
+		`,
+	},
+	{
+		Version: 7,
+		Name:    "remove duplicate notifications and make them unique per user, event and kind",
+		SQL: `
+			DELETE FROM notifications WHERE id NOT IN (
+				SELECT MAX(id) FROM notifications GROUP BY user_id, event_id, kind
+			);
+
+			CREATE UNIQUE INDEX notifications_user_id_event_id_kind ON notifications (user_id, event_id, kind);
+		`,
+	},
+}
+
+// Migrate applies the migrations that were not applied yet, in order, and
+// returns them. Each migration is applied in a transaction with its record.
+func (c *SQLiteClient) Migrate(ctx context.Context) ([]Migration, error) {
+	_, err := c.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at INTEGER NOT NULL
+	)`)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := c.AppliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(map[int]bool, len(applied))
+	for _, migration := range applied {
+		done[migration.Version] = true
+	}
+
+	var ran []Migration
+
+	for _, migration := range sqliteMigrations {
+		if done[migration.Version] {
+			continue
+		}
+
+		log.Printf("[INFO] Applying migration %d: %s", migration.Version, migration.Name)
+
+		if err := c.apply(ctx, migration); err != nil {
+			return ran, fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Name, err)
+		}
+
+		ran = append(ran, Migration{Version: migration.Version, Name: migration.Name})
+	}
+
+	return ran, nil
+}
+
+func (c *SQLiteClient) apply(ctx context.Context, migration sqliteMigration) error {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, migration.SQL); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+		migration.Version, migration.Name, time.Now().UnixMilli())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AppliedMigrations returns the migrations recorded in schema_migrations,
+// none if the database was never migrated.
+func (c *SQLiteClient) AppliedMigrations(ctx context.Context) ([]AppliedMigration, error) {
+	var exists bool
+	err := c.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations')`).Scan(&exists)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	return queryAll(ctx, c.db, func(row scanner) (AppliedMigration, error) {
+		var migration AppliedMigration
+		var appliedAt sql.NullInt64
+
+		err := row.Scan(&migration.Version, &migration.Name, &appliedAt)
+		migration.AppliedAt = fromMillis(appliedAt)
+
+		return migration, err
+	}, `SELECT version, name, applied_at FROM schema_migrations ORDER BY version`)
+}
+
+// Migrations returns every known migration, applied or not.
+func (c *SQLiteClient) Migrations() []Migration {
+	known := make([]Migration, 0, len(sqliteMigrations))
+	for _, migration := range sqliteMigrations {
+		known = append(known, Migration{Version: migration.Version, Name: migration.Name})
+	}
+
+	return known
+}
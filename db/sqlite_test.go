@@ -0,0 +1,28 @@
+package db_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/db/storetest"
+)
+
+func TestSQLiteClient(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) db.Store {
+		ctx := context.Background()
+
+		store, err := db.NewSQLiteClient(":memory:", 0)
+		if err != nil {
+			t.Fatalf("NewSQLiteClient: %v", err)
+		}
+
+		t.Cleanup(func() { store.Close(ctx) })
+
+		if _, err := store.Migrate(ctx); err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+
+		return store
+	})
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned when looking up a document that does not exist.
+var ErrNotFound = errors.New("not found")
+
+// DefaultTimeout bounds each operation of a store, unless the context of the
+// operation expires sooner.
+const DefaultTimeout = 10 * time.Second
+
+type UserStore interface {
+	GetOneByID(ctx context.Context, userID int) (*User, error)
+	GetManyByCampusID(ctx context.Context, campusID int) ([]User, error)
+	InsertOne(ctx context.Context, u User) error
+}
+
+type EventStore interface {
+	GetOneByID(ctx context.Context, eventID int) (*Event, error)
+	GetManyByIDs(ctx context.Context, eventIDs []int) ([]Event, error)
+	GetManyByCampusID(ctx context.Context, campusID int, includeCancelled bool) ([]Event, error)
+	GetUpcoming(ctx context.Context, campusIDs []int) ([]Event, error)
+	UpsertMany(ctx context.Context, events []Event) ([]int, error)
+	Cancel(ctx context.Context, eventID int, at time.Time) error
+}
+
+type CampusStore interface {
+	GetMany(ctx context.Context) ([]Campus, error)
+	GetOneByID(ctx context.Context, campusID int) (*Campus, error)
+	InsertOne(ctx context.Context, c Campus) error
+}
+
+type NotificationStore interface {
+	GetManyByUserID(ctx context.Context, userID int) ([]Notification, error)
+	GetManyByEventID(ctx context.Context, eventID int) ([]Notification, error)
+	InsertMany(ctx context.Context, notifications []Notification) error
+	// InsertManyIfAbsent inserts the notifications whose user does not have
+	// one of the same kind about the same event yet, so that it can be
+	// retried safely.
+	InsertManyIfAbsent(ctx context.Context, notifications []Notification) error
+	// UpsertMany inserts the notifications, replacing the one the user has
+	// of the same kind about the same event if any, so that it comes back
+	// as unread and a retry does not notify twice.
+	UpsertMany(ctx context.Context, notifications []Notification) error
+	SetRead(ctx context.Context, userID int, eventID int, read bool) error
+}
+
+type SyncRunStore interface {
+	GetMany(ctx context.Context, limit int64) ([]SyncRun, error)
+	GetOneByID(ctx context.Context, runID string) (*SyncRun, error)
+	InsertOne(ctx context.Context, r SyncRun) error
+	ReplaceOne(ctx context.Context, r SyncRun) error
+}
+
+type EventRevisionStore interface {
+	GetManyByEventID(ctx context.Context, eventID int) ([]EventRevision, error)
+	InsertOne(ctx context.Context, r EventRevision) error
+}
+
+type SessionStore interface {
+	GetOneByID(ctx context.Context, sessionID string) (*Session, error)
+	GetOneByRefreshHash(ctx context.Context, refreshHash string) (*Session, error)
+	GetActiveByUserID(ctx context.Context, userID int) ([]Session, error)
+	InsertOne(ctx context.Context, s Session) error
+	Rotate(ctx context.Context, sessionID string, at time.Time) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, at time.Time) error
+	Touch(ctx context.Context, sessionID string, at time.Time) error
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+type SigningKeyStore interface {
+	GetMany(ctx context.Context) ([]SigningKey, error)
+	InsertOne(ctx context.Context, k SigningKey) error
+	DeleteExpired(ctx context.Context, before time.Time) error
+}
+
+// Store gives access to every collection. It is implemented by the MongoDB
+// Client, SQLiteClient and MemoryStore.
+type Store interface {
+	Users() UserStore
+	Events() EventStore
+	Campus() CampusStore
+	Notifications() NotificationStore
+	SyncRuns() SyncRunStore
+	EventRevisions() EventRevisionStore
+	Sessions() SessionStore
+	SigningKeys() SigningKeyStore
+	Close(ctx context.Context) error
+}
+
+// MemoryURL is the URL of the in-memory store.
+const MemoryURL = "memory://"
+
+// Open connects to the store url points to: a MongoDB deployment for
+// mongodb:// and mongodb+srv:// URLs, or a SQLite file for sqlite:// URLs such
+// as sqlite://events.db or sqlite:///var/lib/42-events/events.db. memory://
+// keeps the data in memory until the process exits, for tests and local
+// development. Each operation of the store is bounded by timeout, none if it
+// is 0.
+func Open(url string, timeout time.Duration) (Store, error) {
+	switch {
+	case url == "":
+		return nil, errors.New("database URL is not set")
+	case strings.HasPrefix(url, "mongodb://"), strings.HasPrefix(url, "mongodb+srv://"):
+		client, err := NewClient(url, timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		return client, nil
+	case strings.HasPrefix(url, "sqlite://"):
+		client, err := NewSQLiteClient(strings.TrimPrefix(url, "sqlite://"), timeout)
+		if err != nil {
+			return nil, err
+		}
+
+		return client, nil
+	case url == MemoryURL:
+		return NewMemoryStore(), nil
+	}
+
+	return nil, errors.New("unsupported DB_URL scheme, expected mongodb://, sqlite:// or memory://")
+}
+
+// withTimeout bounds ctx by the timeout of the operations of a store, if any.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
@@ -0,0 +1,808 @@
+// Package storetest is a conformance suite for implementations of db.Store.
+// Every backend is expected to pass it, so that they can be swapped without
+// changing the behaviour of the API or the sync:
+//
+//	func TestSQLiteStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) db.Store {
+//			client, err := db.NewSQLiteClient(":memory:", db.DefaultTimeout)
+//			...
+//		})
+//	}
+package storetest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+)
+
+// Run runs the suite, calling newStore for an empty, migrated store in each
+// test. newStore is responsible for closing the store when the test ends.
+func Run(t *testing.T, newStore func(t *testing.T) db.Store) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store db.Store)
+	}{
+		{"Users", testUsers},
+		{"Campus", testCampus},
+		{"EventsUpsert", testEventsUpsert},
+		{"EventsCancel", testEventsCancel},
+		{"EventsQueries", testEventsQueries},
+		{"Notifications", testNotifications},
+		{"SyncRuns", testSyncRuns},
+		{"EventRevisions", testEventRevisions},
+		{"Sessions", testSessions},
+		{"SigningKeys", testSigningKeys},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+// now returns the current time at the millisecond precision stores keep.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Millisecond)
+}
+
+func eventIDs(events []db.Event) []int {
+	ids := make([]int, 0, len(events))
+	for _, event := range events {
+		ids = append(ids, event.EventID)
+	}
+
+	slices.Sort(ids)
+
+	return ids
+}
+
+func testUsers(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	created := now()
+
+	users := []db.User{
+		{UserID: 1, Login: "one", CampusIDs: []int{1}, PrimaryCampusID: 1, LastSeen: created, CreatedAt: created},
+		{UserID: 2, Login: "two", CampusIDs: []int{1, 29}, PrimaryCampusID: 29, Role: db.RoleAdmin, CreatedAt: created},
+		{UserID: 3, Login: "three", CampusIDs: []int{29}},
+	}
+
+	for _, user := range users {
+		if err := store.Users().InsertOne(ctx, user); err != nil {
+			t.Fatalf("InsertOne(%d): %v", user.UserID, err)
+		}
+	}
+
+	user, err := store.Users().GetOneByID(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if user.Login != "two" || user.Role != db.RoleAdmin || user.PrimaryCampusID != 29 || !slices.Equal(user.CampusIDs, []int{1, 29}) {
+		t.Errorf("GetOneByID = %+v, want %+v", *user, users[1])
+	}
+
+	if !user.CreatedAt.Equal(created) {
+		t.Errorf("CreatedAt = %v, want %v", user.CreatedAt, created)
+	}
+
+	if !user.LastSeen.IsZero() {
+		t.Errorf("LastSeen = %v, want zero", user.LastSeen)
+	}
+
+	if _, err := store.Users().GetOneByID(ctx, 4); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetOneByID of a missing user: err = %v, want ErrNotFound", err)
+	}
+
+	campusUsers, err := store.Users().GetManyByCampusID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetManyByCampusID: %v", err)
+	}
+
+	var ids []int
+	for _, user := range campusUsers {
+		ids = append(ids, user.UserID)
+	}
+
+	slices.Sort(ids)
+
+	if !slices.Equal(ids, []int{1, 2}) {
+		t.Errorf("GetManyByCampusID(1) = %v, want [1 2]", ids)
+	}
+}
+
+func testCampus(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	campuses := []db.Campus{
+		{CampusID: 1, Name: "Paris", UserCount: 100, City: "Paris", Country: "France", FetchAttendees: true},
+		{CampusID: 29, Name: "Lyon", City: "Lyon", Country: "France"},
+	}
+
+	for _, campus := range campuses {
+		if err := store.Campus().InsertOne(ctx, campus); err != nil {
+			t.Fatalf("InsertOne(%d): %v", campus.CampusID, err)
+		}
+	}
+
+	campus, err := store.Campus().GetOneByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if *campus != campuses[0] {
+		t.Errorf("GetOneByID = %+v, want %+v", *campus, campuses[0])
+	}
+
+	if _, err := store.Campus().GetOneByID(ctx, 2); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetOneByID of a missing campus: err = %v, want ErrNotFound", err)
+	}
+
+	all, err := store.Campus().GetMany(ctx)
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+
+	if len(all) != 2 {
+		t.Errorf("GetMany returned %d campuses, want 2", len(all))
+	}
+}
+
+func testEventsUpsert(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	begin := now().Add(24 * time.Hour)
+
+	event := db.Event{
+		EventID:      1,
+		Name:         "Workshop",
+		Description:  "Learn things",
+		Location:     "Cluster",
+		Type:         "workshop",
+		Attendees:    2,
+		AttendeeIDs:  []int{10, 11},
+		MaxAttendees: 20,
+		BeginAt:      begin,
+		EndAt:        begin.Add(time.Hour),
+		CampusIDs:    []int{1},
+		CursusIDs:    []int{21},
+		CreatedAt:    begin.Add(-48 * time.Hour),
+		UpdatedAt:    begin.Add(-48 * time.Hour),
+	}
+
+	inserted, err := store.Events().UpsertMany(ctx, []db.Event{event, {EventID: 2, Name: "Talk", BeginAt: begin, EndAt: begin, CampusIDs: []int{1}}})
+	if err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+
+	slices.Sort(inserted)
+	if !slices.Equal(inserted, []int{1, 2}) {
+		t.Errorf("UpsertMany inserted %v, want [1 2]", inserted)
+	}
+
+	stored, err := store.Events().GetOneByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if stored.Name != event.Name || stored.Location != event.Location || stored.MaxAttendees != event.MaxAttendees ||
+		!stored.BeginAt.Equal(event.BeginAt) || !stored.EndAt.Equal(event.EndAt) ||
+		!slices.Equal(stored.AttendeeIDs, event.AttendeeIDs) || !slices.Equal(stored.CursusIDs, event.CursusIDs) {
+		t.Errorf("GetOneByID = %+v, want %+v", *stored, event)
+	}
+
+	// Updating without attendee IDs keeps the stored ones.
+	event.Location = "Auditorium"
+	event.AttendeeIDs = nil
+
+	inserted, err = store.Events().UpsertMany(ctx, []db.Event{event})
+	if err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+
+	if len(inserted) != 0 {
+		t.Errorf("UpsertMany of a stored event inserted %v, want none", inserted)
+	}
+
+	stored, err = store.Events().GetOneByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if stored.Location != "Auditorium" {
+		t.Errorf("Location = %q, want Auditorium", stored.Location)
+	}
+
+	if !slices.Equal(stored.AttendeeIDs, []int{10, 11}) {
+		t.Errorf("AttendeeIDs = %v, want [10 11]", stored.AttendeeIDs)
+	}
+
+	if _, err := store.Events().GetOneByID(ctx, 3); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetOneByID of a missing event: err = %v, want ErrNotFound", err)
+	}
+}
+
+func testEventsCancel(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	begin := now().Add(24 * time.Hour)
+	event := db.Event{EventID: 1, Name: "Workshop", BeginAt: begin, EndAt: begin.Add(time.Hour), CampusIDs: []int{1}}
+
+	if _, err := store.Events().UpsertMany(ctx, []db.Event{event}); err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+
+	cancelledAt := now()
+
+	if err := store.Events().Cancel(ctx, 1, cancelledAt); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+
+	stored, err := store.Events().GetOneByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if !stored.Cancelled || !stored.CancelledAt.Equal(cancelledAt) {
+		t.Errorf("after Cancel, Cancelled = %v and CancelledAt = %v, want true and %v", stored.Cancelled, stored.CancelledAt, cancelledAt)
+	}
+
+	events, err := store.Events().GetManyByCampusID(ctx, 1, false)
+	if err != nil {
+		t.Fatalf("GetManyByCampusID: %v", err)
+	}
+
+	if len(events) != 0 {
+		t.Errorf("GetManyByCampusID without cancelled = %v, want none", eventIDs(events))
+	}
+
+	events, err = store.Events().GetManyByCampusID(ctx, 1, true)
+	if err != nil {
+		t.Fatalf("GetManyByCampusID: %v", err)
+	}
+
+	if !slices.Equal(eventIDs(events), []int{1}) {
+		t.Errorf("GetManyByCampusID with cancelled = %v, want [1]", eventIDs(events))
+	}
+
+	upcoming, err := store.Events().GetUpcoming(ctx, []int{1})
+	if err != nil {
+		t.Fatalf("GetUpcoming: %v", err)
+	}
+
+	if len(upcoming) != 0 {
+		t.Errorf("GetUpcoming = %v, want none", eventIDs(upcoming))
+	}
+
+	// Upserting a cancelled event restores it.
+	if _, err := store.Events().UpsertMany(ctx, []db.Event{event}); err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+
+	stored, err = store.Events().GetOneByID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if stored.Cancelled || !stored.CancelledAt.IsZero() {
+		t.Errorf("after UpsertMany, Cancelled = %v and CancelledAt = %v, want false and zero", stored.Cancelled, stored.CancelledAt)
+	}
+}
+
+func testEventsQueries(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	t0 := now()
+
+	events := []db.Event{
+		// Upcoming at campus 1.
+		{EventID: 1, Name: "upcoming", BeginAt: t0.Add(time.Hour), EndAt: t0.Add(2 * time.Hour), CampusIDs: []int{1}},
+		// Upcoming at campuses 1 and 29.
+		{EventID: 2, Name: "shared", BeginAt: t0.Add(time.Hour), EndAt: t0.Add(2 * time.Hour), CampusIDs: []int{1, 29}},
+		// Started but not ended at campus 1.
+		{EventID: 3, Name: "ongoing", BeginAt: t0.Add(-time.Hour), EndAt: t0.Add(time.Hour), CampusIDs: []int{1}},
+		// Ended at campus 1.
+		{EventID: 4, Name: "past", BeginAt: t0.Add(-2 * time.Hour), EndAt: t0.Add(-time.Hour), CampusIDs: []int{1}},
+		// Upcoming at campus 29.
+		{EventID: 5, Name: "elsewhere", BeginAt: t0.Add(time.Hour), EndAt: t0.Add(2 * time.Hour), CampusIDs: []int{29}},
+	}
+
+	if _, err := store.Events().UpsertMany(ctx, events); err != nil {
+		t.Fatalf("UpsertMany: %v", err)
+	}
+
+	byCampus, err := store.Events().GetManyByCampusID(ctx, 1, false)
+	if err != nil {
+		t.Fatalf("GetManyByCampusID: %v", err)
+	}
+
+	if got := eventIDs(byCampus); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("GetManyByCampusID(1) = %v, want [1 2]", got)
+	}
+
+	upcoming, err := store.Events().GetUpcoming(ctx, []int{1})
+	if err != nil {
+		t.Fatalf("GetUpcoming: %v", err)
+	}
+
+	if got := eventIDs(upcoming); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("GetUpcoming([1]) = %v, want [1 2 3]", got)
+	}
+
+	upcoming, err = store.Events().GetUpcoming(ctx, []int{29, 42})
+	if err != nil {
+		t.Fatalf("GetUpcoming: %v", err)
+	}
+
+	if got := eventIDs(upcoming); !slices.Equal(got, []int{2, 5}) {
+		t.Errorf("GetUpcoming([29 42]) = %v, want [2 5]", got)
+	}
+
+	byIDs, err := store.Events().GetManyByIDs(ctx, []int{2, 4, 6})
+	if err != nil {
+		t.Fatalf("GetManyByIDs: %v", err)
+	}
+
+	if got := eventIDs(byIDs); !slices.Equal(got, []int{2, 4}) {
+		t.Errorf("GetManyByIDs([2 4 6]) = %v, want [2 4]", got)
+	}
+
+	byIDs, err = store.Events().GetManyByIDs(ctx, nil)
+	if err != nil {
+		t.Fatalf("GetManyByIDs: %v", err)
+	}
+
+	if len(byIDs) != 0 {
+		t.Errorf("GetManyByIDs(nil) = %v, want none", eventIDs(byIDs))
+	}
+}
+
+func testNotifications(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	created := now()
+
+	notifications := []db.Notification{
+		{UserID: 1, EventID: 10, Kind: db.NotificationNewEvent, CreatedAt: created},
+		{UserID: 1, EventID: 10, Kind: db.NotificationEventUpdated, CreatedAt: created.Add(time.Minute)},
+		{UserID: 1, EventID: 11, Kind: db.NotificationNewEvent, CreatedAt: created},
+		{UserID: 2, EventID: 10, Kind: db.NotificationNewEvent, CreatedAt: created},
+	}
+
+	if err := store.Notifications().InsertMany(ctx, notifications); err != nil {
+		t.Fatalf("InsertMany: %v", err)
+	}
+
+	byUser, err := store.Notifications().GetManyByUserID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetManyByUserID: %v", err)
+	}
+
+	if len(byUser) != 3 {
+		t.Fatalf("GetManyByUserID(1) returned %d notifications, want 3", len(byUser))
+	}
+
+	if byUser[1].Kind != db.NotificationEventUpdated || !byUser[1].CreatedAt.Equal(created.Add(time.Minute)) {
+		t.Errorf("GetManyByUserID(1)[1] = %+v, want %+v", byUser[1], notifications[1])
+	}
+
+	byEvent, err := store.Notifications().GetManyByEventID(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetManyByEventID: %v", err)
+	}
+
+	if len(byEvent) != 3 {
+		t.Errorf("GetManyByEventID(10) returned %d notifications, want 3", len(byEvent))
+	}
+
+	if err := store.Notifications().SetRead(ctx, 1, 10, true); err != nil {
+		t.Fatalf("SetRead: %v", err)
+	}
+
+	byEvent, err = store.Notifications().GetManyByEventID(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetManyByEventID: %v", err)
+	}
+
+	for _, notification := range byEvent {
+		if want := notification.UserID == 1; notification.HasRead != want {
+			t.Errorf("HasRead of the notification of user %d = %v, want %v", notification.UserID, notification.HasRead, want)
+		}
+	}
+
+	if err := store.Notifications().SetRead(ctx, 1, 10, false); err != nil {
+		t.Fatalf("SetRead: %v", err)
+	}
+
+	byUser, err = store.Notifications().GetManyByUserID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetManyByUserID: %v", err)
+	}
+
+	for _, notification := range byUser {
+		if notification.HasRead {
+			t.Errorf("notification of event %d is read after SetRead false", notification.EventID)
+		}
+	}
+
+	if err := store.Notifications().SetRead(ctx, 2, 10, true); err != nil {
+		t.Fatalf("SetRead: %v", err)
+	}
+
+	retried := []db.Notification{
+		{UserID: 2, EventID: 10, Kind: db.NotificationNewEvent, CreatedAt: created.Add(time.Hour)},
+		{UserID: 2, EventID: 11, Kind: db.NotificationNewEvent, CreatedAt: created.Add(time.Hour)},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := store.Notifications().InsertManyIfAbsent(ctx, retried); err != nil {
+			t.Fatalf("InsertManyIfAbsent: %v", err)
+		}
+	}
+
+	byUser, err = store.Notifications().GetManyByUserID(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetManyByUserID: %v", err)
+	}
+
+	if len(byUser) != 2 {
+		t.Fatalf("GetManyByUserID(2) returned %d notifications after InsertManyIfAbsent, want 2", len(byUser))
+	}
+
+	if !byUser[0].HasRead || !byUser[0].CreatedAt.Equal(created) {
+		t.Errorf("InsertManyIfAbsent replaced the existing notification: %+v", byUser[0])
+	}
+
+	if err := store.Notifications().SetRead(ctx, 1, 10, true); err != nil {
+		t.Fatalf("SetRead: %v", err)
+	}
+
+	updated := []db.Notification{
+		{UserID: 1, EventID: 10, Kind: db.NotificationEventUpdated, CreatedAt: created.Add(2 * time.Hour)},
+		{UserID: 2, EventID: 10, Kind: db.NotificationEventUpdated, CreatedAt: created.Add(2 * time.Hour)},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := store.Notifications().UpsertMany(ctx, updated); err != nil {
+			t.Fatalf("UpsertMany: %v", err)
+		}
+	}
+
+	byEvent, err = store.Notifications().GetManyByEventID(ctx, 10)
+	if err != nil {
+		t.Fatalf("GetManyByEventID: %v", err)
+	}
+
+	updates := make(map[int]int)
+	for _, notification := range byEvent {
+		if notification.Kind != db.NotificationEventUpdated {
+			continue
+		}
+
+		updates[notification.UserID]++
+
+		if notification.HasRead || !notification.CreatedAt.Equal(created.Add(2*time.Hour)) {
+			t.Errorf("UpsertMany left the update of user %d at %+v, want it unread from the new time", notification.UserID, notification)
+		}
+	}
+
+	if updates[1] != 1 || updates[2] != 1 {
+		t.Errorf("UpsertMany left %v updates per user, want one each", updates)
+	}
+}
+
+func testSyncRuns(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	t0 := now()
+
+	for i, runID := range []string{"a", "b", "c"} {
+		run := db.SyncRun{
+			RunID:     runID,
+			Trigger:   "scheduled",
+			Status:    db.SyncRunRunning,
+			StartedAt: t0.Add(time.Duration(i) * time.Minute),
+			Campuses:  []db.SyncRunCampus{},
+			Errors:    []string{},
+		}
+
+		if err := store.SyncRuns().InsertOne(ctx, run); err != nil {
+			t.Fatalf("InsertOne(%s): %v", runID, err)
+		}
+	}
+
+	finished := db.SyncRun{
+		RunID:      "b",
+		Trigger:    "scheduled",
+		Status:     db.SyncRunFailed,
+		StartedAt:  t0.Add(time.Minute),
+		FinishedAt: t0.Add(2 * time.Minute),
+		Added:      1,
+		Failed:     2,
+		APICalls:   3,
+		Campuses:   []db.SyncRunCampus{{CampusID: 1, Name: "Paris", Added: 1, Errors: []string{"boom"}}},
+		Errors:     []string{"boom"},
+	}
+
+	if err := store.SyncRuns().ReplaceOne(ctx, finished); err != nil {
+		t.Fatalf("ReplaceOne: %v", err)
+	}
+
+	run, err := store.SyncRuns().GetOneByID(ctx, "b")
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if run.Status != db.SyncRunFailed || run.Added != 1 || run.Failed != 2 || run.APICalls != 3 ||
+		!run.FinishedAt.Equal(finished.FinishedAt) || !slices.Equal(run.Errors, finished.Errors) {
+		t.Errorf("GetOneByID = %+v, want %+v", *run, finished)
+	}
+
+	if len(run.Campuses) != 1 || run.Campuses[0].Name != "Paris" || !slices.Equal(run.Campuses[0].Errors, []string{"boom"}) {
+		t.Errorf("Campuses = %+v, want %+v", run.Campuses, finished.Campuses)
+	}
+
+	if _, err := store.SyncRuns().GetOneByID(ctx, "d"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetOneByID of a missing run: err = %v, want ErrNotFound", err)
+	}
+
+	runs, err := store.SyncRuns().GetMany(ctx, 2)
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+
+	var ids []string
+	for _, run := range runs {
+		ids = append(ids, run.RunID)
+	}
+
+	if !slices.Equal(ids, []string{"c", "b"}) {
+		t.Errorf("GetMany(2) = %v, want [c b]", ids)
+	}
+}
+
+func testEventRevisions(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	t0 := now()
+
+	revisions := []db.EventRevision{
+		{EventID: 1, Changes: []db.FieldChange{{Field: "location", From: "Cluster", To: "Auditorium"}}, CreatedAt: t0.Add(time.Minute)},
+		{EventID: 1, Changes: []db.FieldChange{{Field: "cancelled", From: false, To: true}}, CreatedAt: t0.Add(2 * time.Minute)},
+		{EventID: 1, Changes: []db.FieldChange{{Field: "name", From: "Talk", To: "Workshop"}}, CreatedAt: t0},
+		{EventID: 2, Changes: []db.FieldChange{{Field: "name", From: "a", To: "b"}}, CreatedAt: t0},
+	}
+
+	for _, revision := range revisions {
+		if err := store.EventRevisions().InsertOne(ctx, revision); err != nil {
+			t.Fatalf("InsertOne: %v", err)
+		}
+	}
+
+	stored, err := store.EventRevisions().GetManyByEventID(ctx, 1)
+	if err != nil {
+		t.Fatalf("GetManyByEventID: %v", err)
+	}
+
+	var fields []string
+	for _, revision := range stored {
+		if len(revision.Changes) != 1 {
+			t.Fatalf("revision has %d changes, want 1", len(revision.Changes))
+		}
+
+		fields = append(fields, revision.Changes[0].Field)
+	}
+
+	if !slices.Equal(fields, []string{"name", "location", "cancelled"}) {
+		t.Errorf("GetManyByEventID(1) fields = %v, want [name location cancelled]", fields)
+	}
+
+	if !stored[0].CreatedAt.Equal(t0) {
+		t.Errorf("CreatedAt = %v, want %v", stored[0].CreatedAt, t0)
+	}
+}
+
+func testSessions(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	t0 := now()
+
+	session := db.Session{
+		SessionID:        "s1",
+		FamilyID:         "s1",
+		UserID:           1,
+		AccessToken:      "sealed-access",
+		RefreshToken:     "sealed-refresh",
+		ExpiresAt:        t0.Add(2 * time.Hour),
+		RefreshHash:      "h1",
+		RefreshExpiresAt: t0.Add(24 * time.Hour),
+		UserAgent:        "Firefox",
+		IP:               "10.0.0.1",
+		StartedAt:        t0.Add(-time.Hour),
+		CreatedAt:        t0,
+		LastUsedAt:       t0,
+	}
+
+	sessions := []db.Session{
+		session,
+		{SessionID: "s2", FamilyID: "s1", UserID: 1, AccessToken: "a", RefreshHash: "h2", CreatedAt: t0},
+		{SessionID: "s3", FamilyID: "s3", UserID: 1, AccessToken: "a", RefreshHash: "h3", RefreshExpiresAt: t0.Add(time.Hour), CreatedAt: t0, LastUsedAt: t0.Add(time.Minute)},
+		{SessionID: "s4", FamilyID: "s4", UserID: 2, AccessToken: "a", RefreshHash: "h4", RefreshExpiresAt: t0.Add(time.Hour), CreatedAt: t0},
+	}
+
+	for _, s := range sessions {
+		if err := store.Sessions().InsertOne(ctx, s); err != nil {
+			t.Fatalf("InsertOne(%s): %v", s.SessionID, err)
+		}
+	}
+
+	stored, err := store.Sessions().GetOneByID(ctx, "s1")
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if stored.FamilyID != "s1" || stored.UserID != 1 || stored.AccessToken != session.AccessToken ||
+		stored.RefreshToken != session.RefreshToken || stored.RefreshHash != "h1" ||
+		!stored.ExpiresAt.Equal(session.ExpiresAt) || !stored.RefreshExpiresAt.Equal(session.RefreshExpiresAt) ||
+		stored.UserAgent != "Firefox" || stored.IP != "10.0.0.1" || !stored.StartedAt.Equal(session.StartedAt) ||
+		!stored.CreatedAt.Equal(t0) || !stored.LastUsedAt.Equal(t0) || !stored.RotatedAt.IsZero() || !stored.RevokedAt.IsZero() {
+		t.Errorf("GetOneByID = %+v, want %+v", *stored, session)
+	}
+
+	if _, err := store.Sessions().GetOneByID(ctx, "s5"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetOneByID of a missing session: err = %v, want ErrNotFound", err)
+	}
+
+	byHash, err := store.Sessions().GetOneByRefreshHash(ctx, "h2")
+	if err != nil || byHash.SessionID != "s2" {
+		t.Errorf("GetOneByRefreshHash(h2) = %v, %v, want s2", byHash, err)
+	}
+
+	if _, err := store.Sessions().GetOneByRefreshHash(ctx, "h5"); !errors.Is(err, db.ErrNotFound) {
+		t.Errorf("GetOneByRefreshHash of a missing hash: err = %v, want ErrNotFound", err)
+	}
+
+	activeIDs := func() []string {
+		t.Helper()
+
+		active, err := store.Sessions().GetActiveByUserID(ctx, 1)
+		if err != nil {
+			t.Fatalf("GetActiveByUserID: %v", err)
+		}
+
+		var ids []string
+		for _, session := range active {
+			ids = append(ids, session.SessionID)
+		}
+
+		return ids
+	}
+
+	// s2 cannot be refreshed, its refresh token has no expiry.
+	if ids := activeIDs(); !slices.Equal(ids, []string{"s3", "s1"}) {
+		t.Errorf("GetActiveByUserID(1) = %v, want [s3 s1]", ids)
+	}
+
+	if err := store.Sessions().Touch(ctx, "s1", t0.Add(5*time.Minute)); err != nil {
+		t.Fatalf("Touch: %v", err)
+	}
+
+	if ids := activeIDs(); !slices.Equal(ids, []string{"s1", "s3"}) {
+		t.Errorf("GetActiveByUserID(1) after Touch(s1) = %v, want [s1 s3]", ids)
+	}
+
+	if ok, err := store.Sessions().Rotate(ctx, "s1", t0.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("Rotate(s1) = %v, %v, want true", ok, err)
+	}
+
+	if ids := activeIDs(); !slices.Equal(ids, []string{"s3"}) {
+		t.Errorf("GetActiveByUserID(1) after Rotate(s1) = %v, want [s3]", ids)
+	}
+
+	if ok, err := store.Sessions().Rotate(ctx, "s1", t0.Add(2*time.Minute)); err != nil || ok {
+		t.Errorf("Rotate(s1) again = %v, %v, want false", ok, err)
+	}
+
+	stored, err = store.Sessions().GetOneByID(ctx, "s1")
+	if err != nil || !stored.RotatedAt.Equal(t0.Add(time.Minute)) {
+		t.Errorf("RotatedAt after Rotate = %v, %v, want %v", stored.RotatedAt, err, t0.Add(time.Minute))
+	}
+
+	if err == nil && (stored.AccessToken != "" || stored.RefreshToken != "") {
+		t.Errorf("Rotate kept the intra tokens of s1")
+	}
+
+	if err := store.Sessions().RevokeFamily(ctx, "s1", t0.Add(3*time.Minute)); err != nil {
+		t.Fatalf("RevokeFamily: %v", err)
+	}
+
+	for id, revoked := range map[string]bool{"s1": true, "s2": true, "s3": false} {
+		stored, err := store.Sessions().GetOneByID(ctx, id)
+		if err != nil {
+			t.Fatalf("GetOneByID(%s): %v", id, err)
+		}
+
+		if stored.RevokedAt.IsZero() == revoked {
+			t.Errorf("RevokedAt of %s = %v, want revoked %v", id, stored.RevokedAt, revoked)
+		}
+
+		if revoked && stored.AccessToken != "" {
+			t.Errorf("RevokeFamily kept the intra tokens of %s", id)
+		}
+	}
+
+	if ok, err := store.Sessions().Rotate(ctx, "s2", t0.Add(4*time.Minute)); err != nil || ok {
+		t.Errorf("Rotate of a revoked session = %v, %v, want false", ok, err)
+	}
+
+	if err := store.Sessions().DeleteExpired(ctx, t0.Add(2*time.Hour)); err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+
+	for id, deleted := range map[string]bool{"s1": false, "s2": false, "s3": true, "s4": true} {
+		if _, err := store.Sessions().GetOneByID(ctx, id); errors.Is(err, db.ErrNotFound) != deleted {
+			t.Errorf("GetOneByID(%s) after DeleteExpired: err = %v, want deleted %v", id, err, deleted)
+		}
+	}
+}
+
+func testSigningKeys(t *testing.T, store db.Store) {
+	ctx := context.Background()
+
+	t0 := now()
+
+	keys := []db.SigningKey{
+		{KeyID: "k1", Algorithm: "EdDSA", PrivateKey: "sealed-1", PublicKey: "public-1", CreatedAt: t0, ExpiresAt: t0.Add(time.Hour)},
+		{KeyID: "k2", Algorithm: "EdDSA", PrivateKey: "sealed-2", PublicKey: "public-2", CreatedAt: t0.Add(time.Minute), ExpiresAt: t0.Add(2 * time.Hour)},
+		{KeyID: "k0", Algorithm: "RS256", PrivateKey: "sealed-0", PublicKey: "public-0", CreatedAt: t0.Add(-time.Hour), ExpiresAt: t0},
+	}
+
+	for _, key := range keys {
+		if err := store.SigningKeys().InsertOne(ctx, key); err != nil {
+			t.Fatalf("InsertOne(%s): %v", key.KeyID, err)
+		}
+	}
+
+	stored, err := store.SigningKeys().GetMany(ctx)
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+
+	var ids []string
+	for _, key := range stored {
+		ids = append(ids, key.KeyID)
+	}
+
+	if !slices.Equal(ids, []string{"k2", "k1", "k0"}) {
+		t.Errorf("GetMany = %v, want [k2 k1 k0]", ids)
+	}
+
+	if key := stored[0]; key.Algorithm != "EdDSA" || key.PrivateKey != "sealed-2" || key.PublicKey != "public-2" ||
+		!key.CreatedAt.Equal(keys[1].CreatedAt) || !key.ExpiresAt.Equal(keys[1].ExpiresAt) {
+		t.Errorf("GetMany()[0] = %+v, want %+v", key, keys[1])
+	}
+
+	if err := store.SigningKeys().DeleteExpired(ctx, t0.Add(time.Minute)); err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+
+	stored, err = store.SigningKeys().GetMany(ctx)
+	if err != nil {
+		t.Fatalf("GetMany: %v", err)
+	}
+
+	ids = nil
+	for _, key := range stored {
+		ids = append(ids, key.KeyID)
+	}
+
+	if !slices.Equal(ids, []string{"k2", "k1"}) {
+		t.Errorf("GetMany after DeleteExpired = %v, want [k2 k1]", ids)
+	}
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Sync run statuses. A run is partial when some of its campuses reported
+// errors, and failed when all of them did or the run could not complete.
+const (
+	SyncRunRunning   string = "running"
+	SyncRunSucceeded string = "succeeded"
+	SyncRunPartial   string = "partial"
+	SyncRunFailed    string = "failed"
+	SyncRunCancelled string = "cancelled"
+)
+
+type SyncRunCampus struct {
+	CampusID  int      `json:"campus_id" bson:"campus_id"`
+	Name      string   `json:"name" bson:"name"`
+	Added     int      `json:"added" bson:"added"`
+	Updated   int      `json:"updated" bson:"updated"`
+	Restored  int      `json:"restored" bson:"restored"`
+	Unchanged int      `json:"unchanged" bson:"unchanged"`
+	Skipped   int      `json:"skipped" bson:"skipped"`
+	Failed    int      `json:"failed" bson:"failed"`
+	APICalls  int      `json:"api_calls" bson:"api_calls"`
+	Retries   int      `json:"retries" bson:"retries"`
+	Errors    []string `json:"errors" bson:"errors"`
+}
+
+type SyncRun struct {
+	RunID      string          `json:"run_id" bson:"run_id"`
+	Trigger    string          `json:"trigger" bson:"trigger"`
+	Status     string          `json:"status" bson:"status"`
+	StartedAt  time.Time       `json:"started_at" bson:"started_at"`
+	FinishedAt time.Time       `json:"finished_at" bson:"finished_at,omitempty"`
+	Added      int             `json:"added" bson:"added"`
+	Updated    int             `json:"updated" bson:"updated"`
+	Restored   int             `json:"restored" bson:"restored"`
+	Cancelled  int             `json:"cancelled" bson:"cancelled"`
+	Unchanged  int             `json:"unchanged" bson:"unchanged"`
+	Skipped    int             `json:"skipped" bson:"skipped"`
+	Failed     int             `json:"failed" bson:"failed"`
+	APICalls   int             `json:"api_calls" bson:"api_calls"`
+	Retries    int             `json:"retries" bson:"retries"`
+	Campuses   []SyncRunCampus `json:"campuses" bson:"campuses"`
+	Errors     []string        `json:"errors" bson:"errors"`
+}
+
+func (coll *SyncRunCollection) GetMany(ctx context.Context, limit int64) ([]SyncRun, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(limit)
+
+	var runs []SyncRun
+
+	cursor, err := coll.collection.Find(ctx, bson.D{}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var run SyncRun
+		err := cursor.Decode(&run)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, run)
+	}
+
+	return runs, nil
+}
+
+func (coll *SyncRunCollection) GetOneByID(ctx context.Context, runID string) (*SyncRun, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "run_id", Value: runID}}
+
+	var run SyncRun
+	err := coll.collection.FindOne(ctx, filter).Decode(&run)
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	return &run, nil
+}
+
+func (coll *SyncRunCollection) InsertOne(ctx context.Context, r SyncRun) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	_, err := coll.collection.InsertOne(ctx, r)
+	return err
+}
+
+func (coll *SyncRunCollection) ReplaceOne(ctx context.Context, r SyncRun) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
+	filter := bson.D{{Key: "run_id", Value: r.RunID}}
+
+	_, err := coll.collection.ReplaceOne(ctx, filter, r)
+	return err
+}
@@ -5,55 +5,37 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
+const RoleAdmin string = "admin"
+
 type User struct {
 	UserID          int       `json:"user_id" bson:"user_id"`
 	Login           string    `json:"login" bson:"login"`
 	ImageURL        string    `json:"image_url" bson:"image_url"`
 	CampusIDs       []int     `json:"campus_ids" bson:"campus_ids"`
 	PrimaryCampusID int       `json:"primary_campus_id" bson:"primary_campus_id,omitempty"`
+	Role            string    `json:"role,omitempty" bson:"role,omitempty"`
 	LastSeen        time.Time `json:"last_seen" bson:"last_seen"`
 	CreatedAt       time.Time `json:"created_at" bson:"created_at"`
 }
 
-func (coll *UserCollection) GetMany() ([]User, error) {
-	var users []User
-
-	cursor, err := coll.collection.Find(context.TODO(), bson.D{})
-	if err != nil {
-		return nil, err
-	}
-
-	defer cursor.Close(context.TODO())
-
-	for cursor.Next(context.TODO()) {
-		var user User
-		err := cursor.Decode(&user)
-		if err != nil {
-			return nil, err
-		}
-
-		users = append(users, user)
-	}
-
-	return users, nil
-}
+func (coll *UserCollection) GetManyByCampusID(ctx context.Context, campusID int) ([]User, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-func (coll *UserCollection) GetManyByCampusID(campusID int) ([]User, error) {
 	filter := bson.D{{Key: "campus_ids", Value: campusID}}
 
 	var users []User
 
-	cursor, err := coll.collection.Find(context.TODO(), filter)
+	cursor, err := coll.collection.Find(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	defer cursor.Close(context.TODO())
+	defer cursor.Close(ctx)
 
-	for cursor.Next(context.TODO()) {
+	for cursor.Next(ctx) {
 		var user User
 		err := cursor.Decode(&user)
 		if err != nil {
@@ -66,27 +48,25 @@ func (coll *UserCollection) GetManyByCampusID(campusID int) ([]User, error) {
 	return users, nil
 }
 
-func (coll *UserCollection) GetOneByID(userID int) (*User, error) {
+func (coll *UserCollection) GetOneByID(ctx context.Context, userID int) (*User, error) {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
+
 	filter := bson.D{{Key: "user_id", Value: userID}}
 
 	var user User
-	err := coll.collection.FindOne(context.TODO(), filter).Decode(&user)
+	err := coll.collection.FindOne(ctx, filter).Decode(&user)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
 	return &user, nil
 }
 
-func (coll *UserCollection) InsertOne(u User) (*mongo.InsertOneResult, error) {
-	return coll.collection.InsertOne(context.TODO(), u)
-}
-
-func (coll *UserCollection) InsertMany(users []User) (*mongo.InsertManyResult, error) {
-	var docs []interface{}
-	for _, user := range users {
-		docs = append(docs, user)
-	}
+func (coll *UserCollection) InsertOne(ctx context.Context, u User) error {
+	ctx, cancel := withTimeout(ctx, coll.timeout)
+	defer cancel()
 
-	return coll.collection.InsertMany(context.TODO(), docs)
+	_, err := coll.collection.InsertOne(ctx, u)
+	return err
 }
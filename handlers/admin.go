@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/herbievine/42-events-api/auth"
+	"github.com/herbievine/42-events-api/db"
+)
+
+// RequireAdmin rejects the requests that authorizeAdmin does not let through.
+// Who made the others is available through adminFrom, for the audit log.
+func RequireAdmin(store db.Store) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			admin, ok := authorizeAdmin(w, r, store)
+			if !ok {
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), adminContextKey, admin)))
+		})
+	}
+}
+
+// adminFrom returns who was authorized by RequireAdmin.
+func adminFrom(r *http.Request) string {
+	admin, _ := r.Context().Value(adminContextKey).(string)
+	return admin
+}
+
+// authorizeAdmin checks that the request comes from an admin, either through
+// the ADMIN_API_KEY in the X-API-Key header or through the JWT of a user with
+// the admin role. It returns who made the request, or writes a 401/403 and
+// returns false.
+func authorizeAdmin(w http.ResponseWriter, r *http.Request, store db.Store) (string, bool) {
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		adminKey := settings.AdminAPIKey
+		if adminKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(adminKey)) != 1 {
+			log.Println("[AUDIT] Rejected admin API key from", r.RemoteAddr, r.Method, r.URL.Path)
+
+			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			return "", false
+		}
+
+		return "api-key", true
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return "", false
+	}
+
+	me, err := auth.Verify(r.Context(), token)
+	if err != nil {
+		http.Error(w, "Invalid JWT", http.StatusUnauthorized)
+		return "", false
+	}
+
+	user, err := store.Users().GetOneByID(r.Context(), me.UserID)
+	if err != nil || user.Role != db.RoleAdmin {
+		log.Println("[AUDIT] Denied user", me.UserID, "from", r.Method, r.URL.Path)
+
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return "", false
+	}
+
+	return "user " + strconv.Itoa(user.UserID) + " (" + user.Login + ")", true
+}
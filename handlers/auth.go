@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/herbievine/42-events-api/auth"
+	"github.com/herbievine/42-events-api/db"
+)
+
+// RequireUser rejects the requests without a valid JWT, and makes the claims
+// of the others available through userFrom.
+func RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := auth.Verify(r.Context(), token)
+		if errors.Is(err, auth.ErrSessionRevoked) {
+			http.Error(w, "Session revoked", http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			http.Error(w, "Invalid JWT", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey, claims)))
+	})
+}
+
+// userFrom returns the claims of the user authenticated by RequireUser.
+func userFrom(r *http.Request) *auth.UserClaims {
+	claims, _ := r.Context().Value(userContextKey).(*auth.UserClaims)
+	return claims
+}
+
+// intraToken returns the intra access token of the session behind the JWT
+// of the request, decrypted. A rotated session handed its tokens over to the
+// latest session of its family, which is used instead.
+func intraToken(r *http.Request, store db.Store) (string, error) {
+	me := userFrom(r)
+
+	session, err := store.Sessions().GetOneByID(r.Context(), me.SessionID)
+	if err != nil {
+		return "", err
+	}
+
+	if !session.RotatedAt.IsZero() {
+		if session, err = familySession(r.Context(), store, me.UserID, session.FamilyID); err != nil {
+			return "", err
+		}
+	}
+
+	return auth.Decrypt(session.AccessToken)
+}
+
+// familySession returns the latest session of a family of the user that can
+// still be refreshed.
+func familySession(ctx context.Context, store db.Store, userID int, familyID string) (*db.Session, error) {
+	sessions, err := store.Sessions().GetActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		if session.FamilyID == familyID {
+			return &session, nil
+		}
+	}
+
+	return nil, db.ErrNotFound
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import "github.com/herbievine/42-events-api/config"
+
+// settings is the configuration of the handlers, see Configure.
+var settings = &config.Config{}
+
+// Configure sets the configuration the handlers read, such as the frontend
+// URL and the admin API key. It must be called before serving requests.
+func Configure(cfg *config.Config) {
+	settings = cfg
+}
@@ -2,68 +2,90 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"sort"
 	"strconv"
-	"time"
 
 	"github.com/herbievine/42-events-api/api"
-	"github.com/herbievine/42-events-api/auth"
 	"github.com/herbievine/42-events-api/db"
-	"go.mongodb.org/mongo-driver/bson"
+	"github.com/herbievine/42-events-api/syncer"
 )
 
-func GetEvent(w http.ResponseWriter, r *http.Request, client *db.Client) {
-	bearer := r.Header.Get("Authorization")
-	if bearer == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+func GetEvent(w http.ResponseWriter, r *http.Request, store db.Store) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 
-	_, err := auth.Verify(bearer[len("Bearer "):])
+	event, err := store.Events().GetOneByID(r.Context(), id)
 	if err != nil {
-		http.Error(w, "Invalid JWT", http.StatusUnauthorized)
+		http.Error(w, "Event not found", http.StatusNotFound)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(event)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetEventHistory(w http.ResponseWriter, r *http.Request, store db.Store) {
 	id, err := strconv.Atoi(r.PathValue("id"))
 	if err != nil {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
 
-	event, err := client.Events().GetOneByID(id)
-	if err != nil {
+	if _, err := store.Events().GetOneByID(r.Context(), id); err != nil {
 		http.Error(w, "Event not found", http.StatusNotFound)
 		return
 	}
 
+	revisions, err := store.EventRevisions().GetManyByEventID(r.Context(), id)
+	if err != nil {
+		http.Error(w, "Failed to get event history", http.StatusInternalServerError)
+		return
+	}
+
+	if revisions == nil {
+		revisions = []db.EventRevision{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 
-	err = json.NewEncoder(w).Encode(event)
+	err = json.NewEncoder(w).Encode(revisions)
 	if err != nil {
 		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
 		return
 	}
 }
 
-func GetEvents(w http.ResponseWriter, r *http.Request, client *db.Client) {
-	bearer := r.Header.Get("Authorization")
-	if bearer == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+func GetEvents(w http.ResponseWriter, r *http.Request, store db.Store) {
+	token, err := intraToken(r, store)
+	if err != nil {
+		log.Println("[WARN] Failed to get intra token:", err)
+
+		http.Error(w, "Invalid session", http.StatusUnauthorized)
 		return
 	}
 
-	me, err := api.Me(bearer)
+	me, err := api.DefaultClient.Me(r.Context(), token)
 	if err != nil {
 		http.Error(w, "Failed to get current user", http.StatusInternalServerError)
 		return
 	}
 
+	includeCancelled := r.URL.Query().Get("include_cancelled") == "true"
+
 	events := make([]db.Event, 0)
 	for _, campus := range me.Campus {
-		campusEvents, err := client.Events().GetManyByCampusID(campus.ID)
+		campusEvents, err := store.Events().GetManyByCampusID(r.Context(), campus.ID, includeCancelled)
 		if err != nil {
 			log.Println(err)
 			http.Error(w, "Failed to get events", http.StatusInternalServerError)
@@ -73,7 +95,7 @@ func GetEvents(w http.ResponseWriter, r *http.Request, client *db.Client) {
 		events = append(events, campusEvents...)
 	}
 
-	campusEvents, err := client.Events().GetManyByCampusID(29)
+	campusEvents, err := store.Events().GetManyByCampusID(r.Context(), 29, includeCancelled)
 	if err != nil {
 		log.Println(err)
 		http.Error(w, "Failed to get events", http.StatusInternalServerError)
@@ -96,167 +118,36 @@ func GetEvents(w http.ResponseWriter, r *http.Request, client *db.Client) {
 }
 
 type NewEventsResponse struct {
-	EventsAdded   int `json:"events_added"`
-	EventsUpdated int `json:"events_updated"`
+	RunID string `json:"run_id"`
 }
 
-func NewEvents(w http.ResponseWriter, r *http.Request, client *db.Client) {
-	token, err := api.GetServerToken()
-	if err != nil {
-		http.Error(w, "Failed to get access token", http.StatusInternalServerError)
-		return
-	}
+func NewEvents(w http.ResponseWriter, r *http.Request, store db.Store, scheduler *syncer.Scheduler) {
+	admin := adminFrom(r)
 
-	campuses, err := client.Campus().GetMany()
-	if err != nil {
-		http.Error(w, "Failed to get campuses", http.StatusInternalServerError)
-		return
-	}
+	id, err := scheduler.Trigger()
+	if errors.Is(err, syncer.ErrAlreadyRunning) {
+		log.Println("[AUDIT]", admin, "triggered sync while", id, "was running")
 
-	var events []db.Event
-	var response NewEventsResponse
-
-	for _, campus := range campuses {
-		campusEvents, err := api.GetEventsByCampusID(token.AccessToken, campus.CampusID)
-		if err != nil {
-			log.Println(err)
-			http.Error(w, "Failed to get events", http.StatusInternalServerError)
-			return
-		}
-
-		campusUsers, err := client.Users().GetManyByCampusID(campus.CampusID)
-		if err != nil {
-			http.Error(w, "Failed to get users for campus", http.StatusInternalServerError)
-			return
-		}
-
-		for _, event := range campusEvents {
-			if event.EndAt.Before(time.Now()) {
-				continue
-			}
-
-			var eventUsers api.EventUsersResponse
-			page := api.Pagination{
-				PageNumber: 1,
-				PageSize:   100,
-			}
-
-			for {
-				resp, err := api.GetEventUsersByEventId(token.AccessToken, event.ID, &page)
-				if err != nil {
-					log.Println("[WARN] Failed to get event users", event.ID, page, err)
-					log.Println("[WARN] Retrying in 10 seconds")
-
-					time.Sleep(10 * time.Second)
-
-					resp, err := api.GetEventUsersByEventId(token.AccessToken, event.ID, &page)
-					if err != nil {
-						log.Println("[WARN] Failed to get event users after retry", event.ID, page, err)
-
-						time.Sleep(2 * time.Second)
-
-						continue
-					}
-
-					eventUsers = append(eventUsers, resp...)
-					page.PageNumber++
-
-					time.Sleep(2 * time.Second)
-
-					continue
-				}
-
-				if len(resp) == 0 {
-					break
-				}
-
-				eventUsers = append(eventUsers, resp...)
-				page.PageNumber++
-
-				time.Sleep(2 * time.Second)
-			}
-
-			eventInDB, err := client.Events().GetOneByID(event.ID)
-			if err != nil {
-				events = append(events, db.Event{
-					EventID:      event.ID,
-					Name:         event.Name,
-					Description:  event.Description,
-					Location:     event.Location,
-					Type:         event.Kind,
-					Attendees:    len(eventUsers),
-					MaxAttendees: event.MaxPeople,
-					BeginAt:      event.BeginAt,
-					EndAt:        event.EndAt,
-					CampusIDs:    event.CampusIds,
-					CursusIDs:    event.CursusIds,
-					CreatedAt:    event.CreatedAt,
-					UpdatedAt:    event.UpdatedAt,
-				})
-
-				var notifications []db.Notification
-				for _, user := range campusUsers {
-					notifications = append(notifications, db.Notification{
-						UserID:    user.UserID,
-						EventID:   event.ID,
-						HasRead:   false,
-						CreatedAt: time.Now(),
-						DeletedAt: time.Time{},
-					})
-				}
-
-				if _, err = client.Notifications().InsertMany(notifications); err != nil {
-					http.Error(w, "Failed to save notifications", http.StatusInternalServerError)
-					return
-				}
-			} else if eventInDB != nil {
-				filter := bson.D{
-					{Key: "event_id", Value: eventInDB.EventID},
-				}
-				update := bson.D{
-					{Key: "$set", Value: bson.D{
-						{Key: "name", Value: event.Name},
-						{Key: "description", Value: event.Description},
-						{Key: "location", Value: event.Location},
-						{Key: "type", Value: event.Kind},
-						{Key: "attendees", Value: len(eventUsers)},
-						{Key: "max_attendees", Value: event.MaxPeople},
-						{Key: "begin_at", Value: event.BeginAt},
-						{Key: "end_at", Value: event.EndAt},
-						{Key: "campus_ids", Value: event.CampusIds},
-						{Key: "cursus_ids", Value: event.CursusIds},
-						{Key: "created_at", Value: event.CreatedAt},
-						{Key: "updated_at", Value: event.UpdatedAt},
-					}},
-				}
-
-				res, err := client.Events().UpdateOneByFilter(filter, update)
-				if err != nil {
-					log.Println("[WARN] Failed to update event", err)
-					continue
-				}
-
-				if res.ModifiedCount == 1 {
-					log.Println("updated event", eventInDB.EventID)
-					response.EventsUpdated++
-				}
-			}
-		}
-	}
-
-	if len(events) == 0 {
-		json.NewEncoder(w).Encode(response)
 		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+
+		json.NewEncoder(w).Encode(NewEventsResponse{RunID: id})
 		return
-	}
+	} else if err != nil {
+		log.Println("[ERROR] Failed to start sync:", err)
 
-	if _, err = client.Events().InsertMany(events); err != nil {
-		http.Error(w, "Failed to save events", http.StatusInternalServerError)
+		http.Error(w, "Failed to start sync", http.StatusServiceUnavailable)
 		return
 	}
 
-	response.EventsAdded = len(events)
+	log.Println("[AUDIT]", admin, "triggered sync", id)
 
-	json.NewEncoder(w).Encode(response)
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+
+	err = json.NewEncoder(w).Encode(NewEventsResponse{RunID: id})
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
 }
@@ -0,0 +1,70 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+)
+
+func TestGetEventHistory(t *testing.T) {
+	ctx := context.Background()
+
+	server, store := newServer(t, false)
+	jwt, _ := login(t, server)
+
+	t0 := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, eventID := range []int{9001, 9002} {
+		if _, err := store.Events().UpsertMany(ctx, []db.Event{{EventID: eventID, Name: "Event"}}); err != nil {
+			t.Fatalf("UpsertMany: %v", err)
+		}
+	}
+
+	revisions := []db.EventRevision{
+		{EventID: 9001, Changes: []db.FieldChange{{Field: "location", From: "Cluster 1", To: "Amphitheatre"}}, CreatedAt: t0},
+		{EventID: 9001, Changes: []db.FieldChange{{Field: "cancelled", From: false, To: true}}, CreatedAt: t0.Add(time.Hour)},
+	}
+
+	for _, revision := range revisions {
+		if err := store.EventRevisions().InsertOne(ctx, revision); err != nil {
+			t.Fatalf("InsertOne: %v", err)
+		}
+	}
+
+	var history []db.EventRevision
+	if res := request(t, server, http.MethodGet, "/events/9001/history", jwt, &history); res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /events/9001/history returned %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	if len(history) != 2 || !history[0].CreatedAt.Equal(t0) || history[1].Changes[0].Field != "cancelled" {
+		t.Errorf("GET /events/9001/history = %+v, want the move then the cancellation", history)
+	}
+
+	if change := history[0].Changes[0]; change.From != "Cluster 1" || change.To != "Amphitheatre" {
+		t.Errorf("first change = %+v, want the location from Cluster 1 to Amphitheatre", change)
+	}
+
+	history = nil
+	if res := request(t, server, http.MethodGet, "/events/9002/history", jwt, &history); res.StatusCode != http.StatusOK || history == nil || len(history) != 0 {
+		t.Errorf("GET /events/9002/history = %d %v, want an empty list", res.StatusCode, history)
+	}
+
+	tests := []struct {
+		path   string
+		jwt    string
+		status int
+	}{
+		{"/events/9003/history", jwt, http.StatusNotFound},
+		{"/events/abc/history", jwt, http.StatusBadRequest},
+		{"/events/9001/history", "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		if res := request(t, server, http.MethodGet, test.path, test.jwt, nil); res.StatusCode != test.status {
+			t.Errorf("GET %s returned %d, want %d", test.path, res.StatusCode, test.status)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/herbievine/42-events-api/auth"
+)
+
+// GetJWKS serves the public keys verifying the JWTs, so that other services
+// can verify them without the server. It lists no keys when the JWTs are
+// signed with the HS256 secret.
+func GetJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	err := json.NewEncoder(w).Encode(auth.JWKS())
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
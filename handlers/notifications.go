@@ -9,32 +9,19 @@ import (
 	"strconv"
 	"time"
 
-	"github.com/herbievine/42-events-api/auth"
 	"github.com/herbievine/42-events-api/db"
-	"go.mongodb.org/mongo-driver/bson"
 )
 
 type NotificationWithEvent struct {
 	db.Event
-	HasRead bool `json:"has_read"`
+	Kind    string `json:"kind"`
+	HasRead bool   `json:"has_read"`
 }
 
-func GetNotifications(w http.ResponseWriter, r *http.Request, client *db.Client) {
-	bearer := r.Header.Get("Authorization")
-	if bearer == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	me, err := auth.Verify(bearer[len("Bearer "):])
-	if err != nil {
-		http.Error(w, "Failed to get current user", http.StatusUnauthorized)
-		return
-	}
-
-	filter := bson.D{{Key: "user_id", Value: me.UserID}}
+func GetNotifications(w http.ResponseWriter, r *http.Request, store db.Store) {
+	me := userFrom(r)
 
-	notifications, err := client.Notifications().GetMany(filter)
+	notifications, err := store.Notifications().GetManyByUserID(r.Context(), me.UserID)
 	if err != nil {
 		http.Error(w, "Failed to get notifications", http.StatusInternalServerError)
 		return
@@ -45,31 +32,47 @@ func GetNotifications(w http.ResponseWriter, r *http.Request, client *db.Client)
 		eventIDs = append(eventIDs, notification.EventID)
 	}
 
-	filter = bson.D{
-		{Key: "event_id", Value: bson.D{
-			{Key: "$in", Value: eventIDs},
-		}},
-		{Key: "begin_at", Value: bson.D{
-			{Key: "$gte", Value: time.Now()},
-		}},
-	}
-
-	events, err := client.Events().GetMany(filter)
+	events, err := store.Events().GetManyByIDs(r.Context(), eventIDs)
 	if err != nil {
 		http.Error(w, "Failed to get events", http.StatusInternalServerError)
 		return
 	}
 
+	now := time.Now()
+
 	eventMap := make(map[int]db.Event)
 	for _, event := range events {
+		if event.BeginAt.Before(now) {
+			continue
+		}
+
 		eventMap[event.EventID] = event
 	}
 
-	notificationsWithEvents := make([]NotificationWithEvent, 0, len(notifications))
-	for _, notification := range notifications {
+	// An event may have several notifications, such as when it was added and
+	// then cancelled. Only the latest one is shown.
+	latest := make(map[int]int)
+	for i, notification := range notifications {
+		if j, ok := latest[notification.EventID]; !ok || notification.CreatedAt.After(notifications[j].CreatedAt) {
+			latest[notification.EventID] = i
+		}
+	}
+
+	notificationsWithEvents := make([]NotificationWithEvent, 0, len(latest))
+	for i, notification := range notifications {
+		if latest[notification.EventID] != i {
+			continue
+		}
+
 		if _, ok := eventMap[notification.EventID]; ok {
+			kind := notification.Kind
+			if kind == "" {
+				kind = db.NotificationNewEvent
+			}
+
 			notificationsWithEvents = append(notificationsWithEvents, NotificationWithEvent{
 				Event:   eventMap[notification.EventID],
+				Kind:    kind,
 				HasRead: notification.HasRead,
 			})
 		}
@@ -96,18 +99,8 @@ func GetNotifications(w http.ResponseWriter, r *http.Request, client *db.Client)
 	}
 }
 
-func ReadNotification(w http.ResponseWriter, r *http.Request, client *db.Client) {
-	bearer := r.Header.Get("Authorization")
-	if bearer == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
-		return
-	}
-
-	me, err := auth.Verify(bearer[len("Bearer "):])
-	if err != nil {
-		http.Error(w, "Failed to get current user", http.StatusUnauthorized)
-		return
-	}
+func ReadNotification(w http.ResponseWriter, r *http.Request, store db.Store) {
+	me := userFrom(r)
 
 	action := r.PathValue("action")
 	if action != "read" && action != "unread" {
@@ -121,13 +114,7 @@ func ReadNotification(w http.ResponseWriter, r *http.Request, client *db.Client)
 		return
 	}
 
-	filter := bson.D{
-		{Key: "event_id", Value: id},
-		{Key: "user_id", Value: me.UserID},
-	}
-	update := bson.D{{Key: "$set", Value: bson.D{{Key: "has_read", Value: action == "read"}}}}
-
-	_, err = client.Notifications().UpdateOneByFilter(filter, update)
+	err = store.Notifications().SetRead(r.Context(), me.UserID, id, action == "read")
 	if err != nil {
 		log.Println("[WARN] Failed to update notification", err)
 
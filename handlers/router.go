@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Router dispatches requests with the method patterns of http.ServeMux, such
+// as "GET /events/{id}". A path registered for some methods answers the
+// others with a 405 and an Allow header, and OPTIONS with the methods it
+// allows.
+type Router struct {
+	mux     *http.ServeMux
+	handler http.Handler
+
+	// methods holds the methods registered for each path.
+	methods map[string][]string
+}
+
+// NewRouter creates a router running every request through middleware, the
+// first one being the outermost.
+func NewRouter(middleware ...Middleware) *Router {
+	mux := http.NewServeMux()
+
+	return &Router{
+		mux:     mux,
+		handler: Chain(mux, middleware...),
+		methods: make(map[string][]string),
+	}
+}
+
+// Handle registers h for method and path, wrapped by middleware that only
+// applies to this route.
+func (rt *Router) Handle(method string, path string, h http.Handler, middleware ...Middleware) {
+	if _, ok := rt.methods[path]; !ok {
+		rt.mux.Handle(http.MethodOptions+" "+path, rt.preflight(path))
+	}
+
+	rt.methods[path] = append(rt.methods[path], method)
+	rt.mux.Handle(method+" "+path, Chain(h, middleware...))
+}
+
+// HandleFunc is like Handle for a handler function.
+func (rt *Router) HandleFunc(method string, path string, h http.HandlerFunc, middleware ...Middleware) {
+	rt.Handle(method, path, h, middleware...)
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.handler.ServeHTTP(w, r)
+}
+
+// preflight answers OPTIONS on path, which includes the CORS preflight.
+func (rt *Router) preflight(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allow := rt.allow(path)
+
+		w.Header().Set("Allow", allow)
+		w.Header().Set("Access-Control-Allow-Methods", allow)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allow lists the methods of path the way ServeMux does in its 405s.
+func (rt *Router) allow(path string) string {
+	methods := append([]string{}, rt.methods[path]...)
+
+	for _, method := range rt.methods[path] {
+		if method == http.MethodGet {
+			methods = append(methods, http.MethodHead)
+		}
+	}
+
+	methods = append(methods, http.MethodOptions)
+	slices.Sort(methods)
+
+	return strings.Join(methods, ", ")
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/syncer"
+)
+
+// route is an entry of the route table.
+type route struct {
+	method     string
+	path       string
+	handler    http.HandlerFunc
+	middleware []Middleware
+}
+
+// Routes builds the router serving the API.
+func Routes(store db.Store, scheduler *syncer.Scheduler) *Router {
+	withStore := func(h func(http.ResponseWriter, *http.Request, db.Store)) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			h(w, r, store)
+		}
+	}
+
+	user := []Middleware{RequireUser}
+	admin := []Middleware{RequireAdmin(store)}
+
+	routes := []route{
+		{"GET", "/health", Health, nil},
+		{"GET", "/.well-known/jwks.json", GetJWKS, nil},
+
+		{"GET", "/auth/login", Login, nil},
+		{"POST", "/token", withStore(GetToken), nil},
+		{"POST", "/token/refresh", withStore(RefreshToken), nil},
+		{"POST", "/logout", withStore(Logout), user},
+		{"GET", "/me", withStore(GetMe), user},
+
+		{"GET", "/sessions", withStore(GetSessions), user},
+		{"DELETE", "/sessions/{id}", withStore(DeleteSession), user},
+
+		{"GET", "/notifications", withStore(GetNotifications), user},
+		{"POST", "/notifications/{action}/{id}", withStore(ReadNotification), user},
+
+		{"GET", "/events", withStore(GetEvents), user},
+		{"POST", "/events", func(w http.ResponseWriter, r *http.Request) {
+			NewEvents(w, r, store, scheduler)
+		}, admin},
+		{"GET", "/events/{id}", withStore(GetEvent), user},
+		{"GET", "/events/{id}/history", withStore(GetEventHistory), user},
+
+		{"GET", "/sync/runs", withStore(GetSyncRuns), admin},
+		{"GET", "/sync/runs/{id}", withStore(GetSyncRun), admin},
+	}
+
+	router := NewRouter(RequestID, Logging, Recover, Cors)
+
+	for _, route := range routes {
+		router.HandleFunc(route.method, route.path, route.handler, route.middleware...)
+	}
+
+	return router
+}
+
+func Health(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	io.WriteString(w, "ok")
+}
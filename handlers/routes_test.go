@@ -0,0 +1,53 @@
+package handlers_test
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRoutesMethods(t *testing.T) {
+	server, _ := newServer(t, false)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		allow  string
+	}{
+		{http.MethodPost, "/health", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{http.MethodDelete, "/events", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST"},
+		{http.MethodPut, "/sessions/abc", http.StatusMethodNotAllowed, "DELETE, OPTIONS"},
+		{http.MethodGet, "/token/refresh", http.StatusMethodNotAllowed, "OPTIONS, POST"},
+
+		// Preflights are answered before RequireUser and RequireAdmin.
+		{http.MethodOptions, "/me", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{http.MethodOptions, "/events/9001/history", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{http.MethodOptions, "/sync/runs", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+
+		// Without a preflight, the routes still require a user.
+		{http.MethodGet, "/me", http.StatusUnauthorized, ""},
+		{http.MethodGet, "/unknown", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		res := request(t, server, tt.method, tt.path, "", nil)
+
+		if res.StatusCode != tt.status {
+			t.Errorf("%s %s returned %d, want %d", tt.method, tt.path, res.StatusCode, tt.status)
+		}
+
+		if allow := res.Header.Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s allows %q, want %q", tt.method, tt.path, allow, tt.allow)
+		}
+
+		if tt.method == http.MethodOptions {
+			if methods := res.Header.Get("Access-Control-Allow-Methods"); methods != tt.allow {
+				t.Errorf("%s %s allows CORS methods %q, want %q", tt.method, tt.path, methods, tt.allow)
+			}
+
+			if origin := res.Header.Get("Access-Control-Allow-Origin"); origin != "http://frontend.test" {
+				t.Errorf("%s %s allows the origin %q, want the frontend", tt.method, tt.path, origin)
+			}
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/herbievine/42-events-api/auth"
+	"github.com/herbievine/42-events-api/db"
+)
+
+// SessionResponse describes a login of the user. Its ID is the one of the
+// session family, which stays the same across refreshes.
+type SessionResponse struct {
+	ID         string    `json:"id"`
+	Device     string    `json:"device"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+func GetSessions(w http.ResponseWriter, r *http.Request, store db.Store) {
+	me := userFrom(r)
+
+	current, err := store.Sessions().GetOneByID(r.Context(), me.SessionID)
+	if err != nil {
+		http.Error(w, "Failed to get session", http.StatusInternalServerError)
+		return
+	}
+
+	sessions, err := store.Sessions().GetActiveByUserID(r.Context(), me.UserID)
+	if err != nil {
+		http.Error(w, "Failed to get sessions", http.StatusInternalServerError)
+		return
+	}
+
+	sessionsResp := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		sessionsResp = append(sessionsResp, SessionResponse{
+			ID:         session.FamilyID,
+			Device:     session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.StartedAt,
+			LastUsedAt: session.LastUsedAt,
+			Current:    session.FamilyID == current.FamilyID,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(sessionsResp)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+// DeleteSession revokes a session of the user, given the ID listed by
+// GetSessions.
+func DeleteSession(w http.ResponseWriter, r *http.Request, store db.Store) {
+	me := userFrom(r)
+	familyID := r.PathValue("id")
+
+	_, err := familySession(r.Context(), store, me.UserID, familyID)
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get session", http.StatusInternalServerError)
+		return
+	}
+
+	if err := revokeFamily(r.Context(), store, familyID); err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	log.Println("[INFO] User", me.UserID, "revoked session", familyID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Logout revokes the session of the request.
+func Logout(w http.ResponseWriter, r *http.Request, store db.Store) {
+	me := userFrom(r)
+
+	session, err := store.Sessions().GetOneByID(r.Context(), me.SessionID)
+	if err != nil {
+		http.Error(w, "Failed to get session", http.StatusInternalServerError)
+		return
+	}
+
+	if err := revokeFamily(r.Context(), store, session.FamilyID); err != nil {
+		http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	clearRefreshCookie(w)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeFamily revokes every session of a family, in the store and in the
+// cache of auth.Verify.
+func revokeFamily(ctx context.Context, store db.Store, familyID string) error {
+	if err := store.Sessions().RevokeFamily(ctx, familyID, time.Now()); err != nil {
+		return err
+	}
+
+	auth.RevokeFamily(familyID)
+
+	return nil
+}
+
+// clientIP returns the address of the client, as forwarded by the reverse
+// proxy if any. It is only meant to be shown to the user.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		ip, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(ip)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,77 @@
+package handlers_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/handlers"
+)
+
+func TestDeleteSession(t *testing.T) {
+	ctx := context.Background()
+
+	server, store := newServer(t, false)
+
+	jwt, _ := login(t, server)
+	_, otherRefresh := login(t, server)
+
+	now := time.Now()
+
+	// A session of another user, which the user must not be able to revoke.
+	err := store.Sessions().InsertOne(ctx, db.Session{
+		SessionID:        "stranger",
+		FamilyID:         "stranger",
+		UserID:           1002,
+		RefreshHash:      "stranger",
+		RefreshExpiresAt: now.Add(time.Hour),
+		CreatedAt:        now,
+		LastUsedAt:       now,
+	})
+	if err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+
+	other := session(t, store, otherRefresh)
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{"session of another user", "stranger", http.StatusNotFound},
+		{"unknown session", "unknown", http.StatusNotFound},
+		{"other session of the user", other.FamilyID, http.StatusNoContent},
+		{"revoked session", other.FamilyID, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		if res := request(t, server, http.MethodDelete, "/sessions/"+test.id, jwt, nil); res.StatusCode != test.status {
+			t.Errorf("DELETE /sessions/{id} of the %s returned %d, want %d", test.name, res.StatusCode, test.status)
+		}
+	}
+
+	stranger, err := store.Sessions().GetOneByID(ctx, "stranger")
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if !stranger.RevokedAt.IsZero() {
+		t.Errorf("session of another user was revoked")
+	}
+
+	if res, _, _ := refresh(t, server, otherRefresh); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST /token/refresh of a deleted session returned %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+
+	var sessions []handlers.SessionResponse
+	if res := request(t, server, http.MethodGet, "/sessions", jwt, &sessions); res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /sessions returned %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	if len(sessions) != 1 || !sessions[0].Current {
+		t.Errorf("GET /sessions = %+v, want only the current session", sessions)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/herbievine/42-events-api/db"
+)
+
+func GetSyncRuns(w http.ResponseWriter, r *http.Request, store db.Store) {
+	log.Println("[AUDIT]", adminFrom(r), "listed sync runs")
+
+	var err error
+	limit := 20
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+
+		if limit > 100 {
+			limit = 100
+		}
+	}
+
+	runs, err := store.SyncRuns().GetMany(r.Context(), int64(limit))
+	if err != nil {
+		http.Error(w, "Failed to get sync runs", http.StatusInternalServerError)
+		return
+	}
+
+	if runs == nil {
+		runs = []db.SyncRun{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(runs)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
+
+func GetSyncRun(w http.ResponseWriter, r *http.Request, store db.Store) {
+	log.Println("[AUDIT]", adminFrom(r), "viewed sync run", r.PathValue("id"))
+
+	run, err := store.SyncRuns().GetOneByID(r.Context(), r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Sync run not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	err = json.NewEncoder(w).Encode(run)
+	if err != nil {
+		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+}
@@ -1,10 +1,11 @@
 package handlers
 
 import (
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -13,72 +14,100 @@ import (
 	"github.com/herbievine/42-events-api/db"
 )
 
-const (
-	baseApiUrl string = "https://api.intra.42.fr"
-)
-
 type tokenResponse struct {
-	JWT string `json:"jwt"`
+	JWT          string `json:"jwt"`
+	RefreshToken string `json:"refresh_token"`
 }
 
-func GetToken(w http.ResponseWriter, r *http.Request, client *db.Client) {
-	code := r.URL.Query().Get("code")
-	state := r.URL.Query().Get("state")
+// stateCookie holds the nonce of the login in progress, see auth.State.
+const stateCookie = "oauth_state"
 
-	if code == "" || state == "" {
-		http.Error(w, "Invalid request", http.StatusBadRequest)
-		return
-	}
-
-	redirectUri := os.Getenv("FRONTEND_URL") + "/auth/callback"
-
-	url, err := r.URL.Parse(baseApiUrl + "/oauth/token")
+// Login redirects to the intra to log in, which then redirects to the
+// callback of the frontend with a code to exchange on POST /token.
+func Login(w http.ResponseWriter, r *http.Request) {
+	state, sealed, err := auth.NewState(settings.API.PKCE)
 	if err != nil {
-		http.Error(w, "Failed to parse URL", http.StatusInternalServerError)
+		log.Println("[ERROR] Failed to create OAuth state:", err)
+
+		http.Error(w, "Failed to start login", http.StatusInternalServerError)
 		return
 	}
 
-	query := url.Query()
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Value:    state.Nonce,
+		Path:     "/token",
+		MaxAge:   int(auth.StateLifetime.Seconds()),
+		HttpOnly: true,
+		Secure:   settings.IsProduction(),
+		SameSite: cookieSameSite(),
+	})
 
-	query.Set("grant_type", "authorization_code")
-	query.Set("client_id", os.Getenv("FORTY_TWO_API_CLIENT"))
-	query.Set("client_secret", os.Getenv("FORTY_TWO_API_SECRET"))
-	query.Set("code", code)
-	query.Set("redirect_uri", redirectUri)
-	query.Set("state", state)
+	http.Redirect(w, r, api.DefaultClient.AuthorizeURL(redirectURI(), sealed, state.Challenge()), http.StatusFound)
+}
 
-	url.RawQuery = query.Encode()
+// GetToken logs in with the code received on the OAuth callback. The state
+// must be the one issued by Login to the same browser.
+func GetToken(w http.ResponseWriter, r *http.Request, store db.Store) {
+	code := r.URL.Query().Get("code")
+	sealed := r.URL.Query().Get("state")
 
-	resp, err := http.Post(url.String(), "application/json", nil)
+	if code == "" || sealed == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	state, err := auth.ParseState(sealed)
 	if err != nil {
-		http.Error(w, "Failed to get access token", http.StatusInternalServerError)
+		log.Println("[WARN] Rejected OAuth state from", r.RemoteAddr, err)
+
+		http.Error(w, "Invalid state", http.StatusBadRequest)
 		return
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		http.Error(w, "Failed to get access token", resp.StatusCode)
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state.Nonce)) != 1 {
+		log.Println("[WARN] Rejected OAuth state from", r.RemoteAddr, "not issued to this browser")
+
+		http.Error(w, "Invalid state", http.StatusBadRequest)
 		return
 	}
 
-	token := api.TokenResponse{}
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookie,
+		Path:     "/token",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   settings.IsProduction(),
+		SameSite: cookieSameSite(),
+	})
 
-	err = json.NewDecoder(resp.Body).Decode(&token)
+	token, err := api.DefaultClient.GetUserToken(r.Context(), code, sealed, redirectURI(), state.Verifier)
 	if err != nil {
-		http.Error(w, "Failed to parse response body", http.StatusInternalServerError)
+		log.Println("[WARN] Failed to get access token:", err)
+
+		var statusErr *api.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode < 500 {
+			http.Error(w, "Failed to get access token", statusErr.StatusCode)
+			return
+		}
+
+		http.Error(w, "Failed to get access token", http.StatusInternalServerError)
 		return
 	}
 
-	me, err := api.Me(token.AccessToken)
+	me, err := api.DefaultClient.Me(r.Context(), token.AccessToken)
 	if err != nil {
-		log.Fatalln(err)
+		log.Println("[WARN] Failed to get current user:", err)
+
 		http.Error(w, "Failed to get current user", http.StatusInternalServerError)
 		return
 	}
 
-	if _, err := client.Users().GetOneByID(me.ID); err != nil {
+	if _, err := store.Users().GetOneByID(r.Context(), me.ID); err != nil {
 		for _, campus := range me.Campus {
-			if _, err := client.Campus().GetOneByID(campus.ID); err != nil {
-				_, err := client.Campus().InsertOne(db.Campus{
+			if _, err := store.Campus().GetOneByID(r.Context(), campus.ID); err != nil {
+				err := store.Campus().InsertOne(r.Context(), db.Campus{
 					CampusID:  campus.ID,
 					Name:      campus.Name,
 					UserCount: campus.UsersCount,
@@ -103,7 +132,7 @@ func GetToken(w http.ResponseWriter, r *http.Request, client *db.Client) {
 			}
 		}
 
-		_, err = client.Users().InsertOne(db.User{
+		err = store.Users().InsertOne(r.Context(), db.User{
 			UserID:          me.ID,
 			Login:           me.Login,
 			ImageURL:        me.Image.Link,
@@ -118,11 +147,264 @@ func GetToken(w http.ResponseWriter, r *http.Request, client *db.Client) {
 		}
 	}
 
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	session := db.Session{
+		SessionID: sessionID,
+		FamilyID:  sessionID,
+		UserID:    me.ID,
+	}
+
+	if err := sealIntraToken(&session, token); err != nil {
+		log.Println("[ERROR] Failed to encrypt intra token:", err)
+
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	issueTokens(w, r, store, session)
+}
+
+// RefreshToken rotates the session of the refresh token found in the
+// refresh_token cookie or in the body, issuing a new JWT and refresh token.
+// The intra token of the session is refreshed as well when it is about to
+// expire. A refresh token that was already used revokes its whole family, as
+// either the client or an attacker holds a stolen copy.
+func RefreshToken(w http.ResponseWriter, r *http.Request, store db.Store) {
+	refreshToken := ""
+
+	if cookie, err := r.Cookie(refreshCookie); err == nil {
+		refreshToken = cookie.Value
+	} else {
+		var body refreshRequest
+		if err := json.NewDecoder(r.Body).Decode(&body); err == nil {
+			refreshToken = body.RefreshToken
+		}
+	}
+
+	if refreshToken == "" {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	session, err := store.Sessions().GetOneByRefreshHash(r.Context(), auth.HashToken(refreshToken))
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+		return
+	} else if err != nil {
+		http.Error(w, "Failed to get session", http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+
+	if !session.RevokedAt.IsZero() {
+		clearRefreshCookie(w)
+
+		http.Error(w, "Session revoked", http.StatusUnauthorized)
+		return
+	}
+
+	if now.After(session.RefreshExpiresAt) {
+		clearRefreshCookie(w)
+
+		http.Error(w, "Session expired", http.StatusUnauthorized)
+		return
+	}
+
+	if !session.RotatedAt.IsZero() {
+		rejectReuse(w, r, store, session)
+		return
+	}
+
+	sessionID, err := auth.NewSessionID()
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+
+	next := db.Session{
+		SessionID:    sessionID,
+		FamilyID:     session.FamilyID,
+		UserID:       session.UserID,
+		AccessToken:  session.AccessToken,
+		RefreshToken: session.RefreshToken,
+		ExpiresAt:    session.ExpiresAt,
+		StartedAt:    session.StartedAt,
+	}
+
+	if session.RefreshToken != "" && now.Add(intraRefreshMargin).After(session.ExpiresAt) {
+		if !refreshIntraToken(w, r, store, &next) {
+			return
+		}
+	}
+
+	// The next session is saved before the current one is rotated, so that a
+	// failure in between leaves the client with a refresh token that works.
+	// A next session that is never issued expires unused.
+	newRefreshToken, ok := saveSession(w, r, store, &next)
+	if !ok {
+		return
+	}
+
+	rotated, err := store.Sessions().Rotate(r.Context(), session.SessionID, now)
+	if err != nil {
+		http.Error(w, "Failed to rotate session", http.StatusInternalServerError)
+		return
+	}
+
+	if !rotated {
+		rejectReuse(w, r, store, session)
+		return
+	}
+
+	writeTokens(w, next, newRefreshToken)
+}
+
+// rejectReuse revokes the family of a session whose refresh token was used
+// again, including the session saved for this use, and writes a 401.
+func rejectReuse(w http.ResponseWriter, r *http.Request, store db.Store, session *db.Session) {
+	log.Println("[AUDIT] Reused refresh token of session", session.SessionID, "of user", session.UserID, "revoking family", session.FamilyID)
+
+	if err := revokeFamily(r.Context(), store, session.FamilyID); err != nil {
+		log.Println("[ERROR] Failed to revoke session family", session.FamilyID, err)
+	}
+
+	clearRefreshCookie(w)
+
+	http.Error(w, "Invalid refresh token", http.StatusUnauthorized)
+}
+
+// redirectURI is where the intra sends users back to after they logged in.
+func redirectURI() string {
+	return settings.FrontendURL + "/auth/callback"
+}
+
+// intraRefreshMargin is how long before its expiry the intra token of a
+// session is refreshed.
+const intraRefreshMargin = 5 * time.Minute
+
+// refreshCookie holds the refresh token in browsers, out of reach of scripts.
+const refreshCookie = "refresh_token"
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// refreshIntraToken replaces the intra token of session with a new one. When
+// the intra refuses the refresh token, the family of session is revoked and a
+// 401 is written. Other failures keep the current token, to be refreshed on
+// the next rotation.
+func refreshIntraToken(w http.ResponseWriter, r *http.Request, store db.Store, session *db.Session) bool {
+	refreshToken, err := auth.Decrypt(session.RefreshToken)
+	if err != nil {
+		log.Println("[ERROR] Failed to decrypt intra refresh token:", err)
+
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return false
+	}
+
+	token, err := api.DefaultClient.RefreshUserToken(r.Context(), refreshToken)
+
+	var statusErr *api.StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode < 500 {
+		log.Println("[WARN] Intra refused to refresh the token of session family", session.FamilyID, err)
+
+		if err := revokeFamily(r.Context(), store, session.FamilyID); err != nil {
+			log.Println("[ERROR] Failed to revoke session family", session.FamilyID, err)
+		}
+
+		clearRefreshCookie(w)
+
+		http.Error(w, "Session expired", http.StatusUnauthorized)
+		return false
+	} else if err != nil {
+		log.Println("[WARN] Failed to refresh intra token, keeping the current one:", err)
+		return true
+	}
+
+	if err := sealIntraToken(session, token); err != nil {
+		log.Println("[ERROR] Failed to encrypt intra token:", err)
+
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return false
+	}
+
+	return true
+}
+
+// sealIntraToken stores token in session, encrypted.
+func sealIntraToken(session *db.Session, token *api.TokenResponse) error {
+	accessToken, err := auth.Encrypt(token.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := auth.Encrypt(token.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	session.AccessToken = accessToken
+	session.RefreshToken = refreshToken
+	session.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+
+	return nil
+}
+
+// issueTokens stores session with a new refresh token, then writes a JWT for
+// the session along with the refresh token.
+func issueTokens(w http.ResponseWriter, r *http.Request, store db.Store, session db.Session) {
+	refreshToken, ok := saveSession(w, r, store, &session)
+	if !ok {
+		return
+	}
+
+	writeTokens(w, session, refreshToken)
+}
+
+// saveSession stores session with a new refresh token, which it returns. On
+// failure, it writes a 500 and reports false.
+func saveSession(w http.ResponseWriter, r *http.Request, store db.Store, session *db.Session) (string, bool) {
+	refreshToken, err := auth.NewRefreshToken()
+	if err != nil {
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return "", false
+	}
+
+	now := time.Now()
+
+	session.RefreshHash = auth.HashToken(refreshToken)
+	session.RefreshExpiresAt = now.Add(settings.RefreshLifetime)
+	session.UserAgent = r.UserAgent()
+	session.IP = clientIP(r)
+	session.CreatedAt = now
+	session.LastUsedAt = now
+
+	if session.StartedAt.IsZero() {
+		session.StartedAt = now
+	}
+
+	if err := store.Sessions().InsertOne(r.Context(), *session); err != nil {
+		http.Error(w, "Failed to save session", http.StatusInternalServerError)
+		return "", false
+	}
+
+	return refreshToken, true
+}
+
+// writeTokens writes a JWT for session along with its refresh token, which
+// is also set as a cookie.
+func writeTokens(w http.ResponseWriter, session db.Session, refreshToken string) {
 	jwtClaims := auth.UserClaims{
-		UserID:      me.ID,
-		AccessToken: token.AccessToken,
+		UserID:    session.UserID,
+		SessionID: session.SessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute * 15)),
+			ExpiresAt: jwt.NewNumericDate(session.CreatedAt.Add(settings.JWTLifetime)),
 		},
 	}
 
@@ -134,8 +416,19 @@ func GetToken(w http.ResponseWriter, r *http.Request, client *db.Client) {
 		return
 	}
 
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Value:    refreshToken,
+		Path:     "/token",
+		Expires:  session.RefreshExpiresAt,
+		HttpOnly: true,
+		Secure:   settings.IsProduction(),
+		SameSite: cookieSameSite(),
+	})
+
 	jwtResp := tokenResponse{
-		JWT: jwt,
+		JWT:          jwt,
+		RefreshToken: refreshToken,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -146,3 +439,24 @@ func GetToken(w http.ResponseWriter, r *http.Request, client *db.Client) {
 		return
 	}
 }
+
+func clearRefreshCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookie,
+		Path:     "/token",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   settings.IsProduction(),
+		SameSite: cookieSameSite(),
+	})
+}
+
+// cookieSameSite lets the cookies reach the API from the frontend in
+// production, where they are served from different sites over HTTPS.
+func cookieSameSite() http.SameSite {
+	if settings.IsProduction() {
+		return http.SameSiteNoneMode
+	}
+
+	return http.SameSiteLaxMode
+}
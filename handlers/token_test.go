@@ -0,0 +1,418 @@
+package handlers_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/herbievine/42-events-api/api"
+	"github.com/herbievine/42-events-api/api/fakeintra"
+	"github.com/herbievine/42-events-api/auth"
+	"github.com/herbievine/42-events-api/config"
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/handlers"
+	"github.com/herbievine/42-events-api/syncer"
+)
+
+// noRedirect returns redirects instead of following them.
+var noRedirect = &http.Client{
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// newServer serves the routes with an in-memory store, talking to a fake
+// intra serving the default fixtures.
+func newServer(t *testing.T, pkce bool) (*httptest.Server, db.Store) {
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	t.Cleanup(fake.Close)
+
+	return newServerWith(t, fake, pkce)
+}
+
+// newServerWith is like newServer, talking to the given fake intra.
+func newServerWith(t *testing.T, fake *fakeintra.Server, pkce bool) (*httptest.Server, db.Store) {
+	api.DefaultClient = fake.APIClient()
+
+	auth.SetSecret("test-secret")
+
+	if err := auth.SetEncryptionKey(base64.StdEncoding.EncodeToString(make([]byte, 32))); err != nil {
+		t.Fatalf("SetEncryptionKey: %v", err)
+	}
+
+	handlers.Configure(&config.Config{
+		Env:             config.Development,
+		FrontendURL:     "http://frontend.test",
+		JWTLifetime:     15 * time.Minute,
+		RefreshLifetime: time.Hour,
+		API:             config.API{PKCE: pkce},
+	})
+
+	store := db.NewMemoryStore()
+	auth.SetSessionStore(store.Sessions())
+
+	scheduler := syncer.NewScheduler(func(ctx context.Context, runID string, trigger string) error {
+		return nil
+	}, 0, 0)
+
+	server := httptest.NewServer(handlers.Routes(store, scheduler))
+	t.Cleanup(server.Close)
+
+	return server, store
+}
+
+// authorize starts a login and follows it to the intra, returning the code
+// and state the intra sends back to the frontend, and the state cookie.
+func authorize(t *testing.T, server *httptest.Server) (string, string, *http.Cookie) {
+	res, err := noRedirect.Get(server.URL + "/auth/login")
+	if err != nil {
+		t.Fatalf("GET /auth/login: %v", err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("GET /auth/login returned %d, want %d", res.StatusCode, http.StatusFound)
+	}
+
+	cookies := res.Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("GET /auth/login set %d cookies, want 1", len(cookies))
+	}
+
+	res, err = noRedirect.Get(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("GET /oauth/authorize: %v", err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Fatalf("GET /oauth/authorize returned %d, want %d", res.StatusCode, http.StatusFound)
+	}
+
+	callback, err := url.Parse(res.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("parse callback: %v", err)
+	}
+
+	return callback.Query().Get("code"), callback.Query().Get("state"), cookies[0]
+}
+
+// exchange posts the code and state to /token as the frontend does, relaying
+// the state as it received it.
+func exchange(t *testing.T, server *httptest.Server, code string, state string, cookie *http.Cookie) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/token?code="+url.QueryEscape(code)+"&state="+state, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("POST /token: %v", err)
+	}
+
+	t.Cleanup(func() { res.Body.Close() })
+
+	return res
+}
+
+// login logs in as the user of the default fixtures, returning the JWT and
+// refresh token issued by POST /token.
+func login(t *testing.T, server *httptest.Server) (string, string) {
+	code, state, cookie := authorize(t, server)
+
+	res := exchange(t, server, code, state, cookie)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST /token returned %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	var tokens struct {
+		JWT          string `json:"jwt"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+		t.Fatalf("decode POST /token: %v", err)
+	}
+
+	return tokens.JWT, tokens.RefreshToken
+}
+
+// request sends a request to the server with the JWT, if any, and decodes a
+// successful JSON response into v, if not nil.
+func request(t *testing.T, server *httptest.Server, method string, path string, jwt string, v interface{}) *http.Response {
+	req, err := http.NewRequest(method, server.URL+path, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	if jwt != "" {
+		req.Header.Set("Authorization", "Bearer "+jwt)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+
+	t.Cleanup(func() { res.Body.Close() })
+
+	if v != nil && res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(v); err != nil {
+			t.Fatalf("decode %s %s: %v", method, path, err)
+		}
+	}
+
+	return res
+}
+
+func TestLogin(t *testing.T) {
+	for _, pkce := range []bool{false, true} {
+		server, store := newServer(t, pkce)
+
+		code, state, cookie := authorize(t, server)
+
+		if strings.ContainsAny(state, "+/=") {
+			t.Errorf("state %q is not URL-safe", state)
+		}
+
+		res := exchange(t, server, code, state, cookie)
+		if res.StatusCode != http.StatusOK {
+			body, _ := io.ReadAll(res.Body)
+			t.Fatalf("POST /token with PKCE %v returned %d %s, want %d", pkce, res.StatusCode, body, http.StatusOK)
+		}
+
+		var tokens struct {
+			JWT          string `json:"jwt"`
+			RefreshToken string `json:"refresh_token"`
+		}
+
+		if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+			t.Fatalf("decode POST /token: %v", err)
+		}
+
+		if tokens.JWT == "" || tokens.RefreshToken == "" {
+			t.Fatalf("POST /token returned %+v, want a JWT and a refresh token", tokens)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/me", nil)
+		if err != nil {
+			t.Fatalf("NewRequest: %v", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+tokens.JWT)
+
+		me, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /me: %v", err)
+		}
+
+		var user db.User
+		err = json.NewDecoder(me.Body).Decode(&user)
+		me.Body.Close()
+
+		if err != nil || user.UserID != 1001 || user.Login != "jdoe" {
+			t.Errorf("GET /me returned %+v (%v), want user 1001 jdoe", user, err)
+		}
+
+		campus, err := store.Campus().GetOneByID(context.Background(), 1)
+		if err != nil || campus == nil {
+			t.Errorf("campus 1 was not stored on login: %v", err)
+		}
+	}
+}
+
+func TestLoginRejectsState(t *testing.T) {
+	server, _ := newServer(t, false)
+
+	code, state, cookie := authorize(t, server)
+	_, otherState, otherCookie := authorize(t, server)
+
+	tampered := []byte(state)
+	tampered[len(tampered)/2] ^= 1
+
+	tests := []struct {
+		name   string
+		state  string
+		cookie *http.Cookie
+	}{
+		{"no cookie", state, nil},
+		{"cookie of another login", state, otherCookie},
+		{"state of another login", otherState, cookie},
+		{"tampered state", string(tampered), cookie},
+	}
+
+	for _, test := range tests {
+		res := exchange(t, server, code, test.state, test.cookie)
+		if res.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /token with %s returned %d, want %d", test.name, res.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+// refresh posts the refresh token to /token/refresh in the body, returning
+// the response and the tokens it issued, if any.
+func refresh(t *testing.T, server *httptest.Server, refreshToken string) (*http.Response, string, string) {
+	body := strings.NewReader(`{"refresh_token":"` + refreshToken + `"}`)
+
+	res, err := http.Post(server.URL+"/token/refresh", "application/json", body)
+	if err != nil {
+		t.Fatalf("POST /token/refresh: %v", err)
+	}
+
+	defer res.Body.Close()
+
+	var tokens struct {
+		JWT          string `json:"jwt"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if res.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(res.Body).Decode(&tokens); err != nil {
+			t.Fatalf("decode POST /token/refresh: %v", err)
+		}
+	}
+
+	return res, tokens.JWT, tokens.RefreshToken
+}
+
+// session returns the session of a refresh token.
+func session(t *testing.T, store db.Store, refreshToken string) *db.Session {
+	t.Helper()
+
+	session, err := store.Sessions().GetOneByRefreshHash(context.Background(), auth.HashToken(refreshToken))
+	if err != nil {
+		t.Fatalf("GetOneByRefreshHash: %v", err)
+	}
+
+	return session
+}
+
+func TestRefreshToken(t *testing.T) {
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	server, store := newServerWith(t, fake, false)
+	_, first := login(t, server)
+
+	res, jwt, second := refresh(t, server, first)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST /token/refresh returned %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	if second == first {
+		t.Errorf("POST /token/refresh issued the same refresh token")
+	}
+
+	rotated, next := session(t, store, first), session(t, store, second)
+
+	if rotated.RotatedAt.IsZero() || rotated.AccessToken != "" || rotated.RefreshToken != "" {
+		t.Errorf("rotated session = %+v, want it rotated without intra tokens", rotated)
+	}
+
+	if next.FamilyID != rotated.FamilyID || !next.RotatedAt.IsZero() || next.AccessToken == "" || next.RefreshToken == "" {
+		t.Errorf("next session = %+v, want the intra tokens in the same family", next)
+	}
+
+	// The intra token is far from expiring, so it is handed over as is.
+	if requests := fake.Requests("/oauth/token"); requests != 1 {
+		t.Errorf("intra received %d token requests, want 1", requests)
+	}
+
+	if res := request(t, server, http.MethodGet, "/sessions", jwt, nil); res.StatusCode != http.StatusOK {
+		t.Errorf("GET /sessions with the new JWT returned %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	if res, _, _ := refresh(t, server, second); res.StatusCode != http.StatusOK {
+		t.Errorf("POST /token/refresh with the new refresh token returned %d, want %d", res.StatusCode, http.StatusOK)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	server, store := newServer(t, false)
+	_, first := login(t, server)
+
+	_, jwt, second := refresh(t, server, first)
+
+	if res, _, _ := refresh(t, server, first); res.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("POST /token/refresh reusing a refresh token returned %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+
+	if res, _, _ := refresh(t, server, second); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST /token/refresh after a reuse returned %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+
+	if res := request(t, server, http.MethodGet, "/sessions", jwt, nil); res.StatusCode != http.StatusUnauthorized {
+		t.Errorf("GET /sessions after a reuse returned %d, want %d", res.StatusCode, http.StatusUnauthorized)
+	}
+
+	for _, refreshToken := range []string{first, second} {
+		if s := session(t, store, refreshToken); s.RevokedAt.IsZero() || s.AccessToken != "" || s.RefreshToken != "" {
+			t.Errorf("session after a reuse = %+v, want it revoked without intra tokens", s)
+		}
+	}
+
+	sessions, err := store.Sessions().GetActiveByUserID(context.Background(), 1001)
+	if err != nil {
+		t.Fatalf("GetActiveByUserID: %v", err)
+	}
+
+	if len(sessions) != 0 {
+		t.Errorf("user has %d active sessions after a reuse, want 0", len(sessions))
+	}
+}
+
+func TestRefreshTokenRefreshesIntraToken(t *testing.T) {
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	fake.TokenLifetime = time.Minute
+
+	server, store := newServerWith(t, fake, false)
+	_, first := login(t, server)
+
+	accessToken, err := auth.Decrypt(session(t, store, first).AccessToken)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+
+	res, _, second := refresh(t, server, first)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("POST /token/refresh returned %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	if requests := fake.Requests("/oauth/token"); requests != 2 {
+		t.Errorf("intra received %d token requests, want 2", requests)
+	}
+
+	next := session(t, store, second)
+
+	refreshed, err := auth.Decrypt(next.AccessToken)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+
+	if refreshed == accessToken {
+		t.Errorf("next session kept the intra token %q, want a new one", accessToken)
+	}
+
+	if !next.ExpiresAt.After(time.Now()) {
+		t.Errorf("next session intra token expires at %v, want later", next.ExpiresAt)
+	}
+
+	if rotated := session(t, store, first); rotated.AccessToken != "" || rotated.RefreshToken != "" {
+		t.Errorf("rotated session = %+v, want it without intra tokens", rotated)
+	}
+}
@@ -2,131 +2,152 @@ package main
 
 import (
 	"context"
-	"io"
 	"log"
 	"net/http"
 	"os"
-
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/herbievine/42-events-api/api"
+	"github.com/herbievine/42-events-api/auth"
+	"github.com/herbievine/42-events-api/config"
 	"github.com/herbievine/42-events-api/db"
 	"github.com/herbievine/42-events-api/handlers"
-	"github.com/joho/godotenv"
+	"github.com/herbievine/42-events-api/syncer"
 )
 
 func main() {
-	godotenv.Load()
+	cfg, err := config.Load()
 
-	serverAddr := ":3000"
+	if len(os.Args) > 1 && os.Args[1] == "config" && cfg != nil {
+		cfg.Dump(os.Stdout)
+	}
 
-	client, err := db.NewClient()
 	if err != nil {
-		log.Fatalln(err)
+		log.Fatalln("[ERROR] Invalid configuration:", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		return
 	}
 
-	defer client.Close(context.TODO())
+	auth.SetSecret(cfg.JWTSecret)
+
+	if err := auth.SetEncryptionKey(cfg.SessionKey); err != nil {
+		log.Fatalln("[ERROR] Invalid session key:", err)
+	}
+
+	handlers.Configure(cfg)
+
+	api.DefaultClient = api.NewDefaultClient(cfg.API.URL, cfg.API.Timeout)
+	api.DefaultClient.SetCredentials(cfg.API.ClientID, cfg.API.ClientSecret)
+
+	store, err := db.Open(cfg.DB.URL, cfg.DB.Timeout)
+	if err != nil {
+		log.Fatalln(err)
+	}
 
 	log.Println("[INFO] Connected to database")
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "GET" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
+	auth.SetSessionStore(store.Sessions())
 
-		io.WriteString(w, "ok")
-		w.Header().Set("Content-Type", "text/plain")
-	})
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	http.HandleFunc("/me", handlers.WithCors(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "OPTIONS" {
-			return
-		} else if r.Method == "GET" {
-			handlers.GetMe(w, r, client)
-			return
-		}
+	syncService := syncer.NewService(api.DefaultClient, store, cfg.Sync.Workers)
 
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}))
-
-	http.HandleFunc("/notifications/{action}/{id}", handlers.WithCors(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "POST" {
-			handlers.ReadNotification(w, r, client)
-			return
-		} else if r.Method == "OPTIONS" {
-			return
-		}
+	if len(os.Args) > 1 {
+		err := runCommand(ctx, os.Args[1:], store, syncService)
+		store.Close(context.Background())
 
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}))
-
-	http.HandleFunc("/notifications", handlers.WithCors(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			handlers.GetNotifications(w, r, client)
-			return
-		} else if r.Method == "OPTIONS" {
-			return
+		if err != nil {
+			log.Fatalln(err)
 		}
 
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
-	}))
-
-	// http.HandleFunc("GET /notifications/{state}", handlers.WithCors(func(w http.ResponseWriter, r *http.Request) {
-	// 	if r.PathValue("state") == "old" {
-	// 		handlers.GetOldNotifications(w, r, client)
-	// 		return
-	// 	}
-
-	// 	handlers.GetNotifications(w, r, client)
-	// 	return
-	// }))
-
-	http.HandleFunc("/events/{id}", handlers.WithCors(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "OPTIONS" {
-			return
-		} else if r.Method == "GET" {
-			handlers.GetEvent(w, r, client)
-			return
+	}
+
+	if migrator, ok := store.(db.Migrator); ok && cfg.DB.AutoMigrate {
+		if _, err := migrator.Migrate(ctx); err != nil {
+			log.Fatalln(err)
 		}
+	}
 
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}))
-
-	http.HandleFunc("/events", handlers.WithCors(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "OPTIONS" {
-			return
-		} else if r.Method == "GET" {
-			handlers.GetEvents(w, r, client)
-			return
-		} else if r.Method == "POST" {
-			handlers.NewEvents(w, r, client)
-			return
+	go auth.RunSessionCleanup(ctx, store.Sessions())
+
+	if cfg.JWTAlgorithm != auth.HS256 {
+		if err := auth.UseKeys(ctx, store.SigningKeys(), cfg.JWTAlgorithm, cfg.JWTKeyRotation, cfg.JWTLifetime); err != nil {
+			log.Fatalln("[ERROR] Failed to load signing keys:", err)
 		}
 
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}))
-
-	http.HandleFunc("/token", handlers.WithCors(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "OPTIONS" {
-			return
-		} else if r.Method == "POST" {
-			handlers.GetToken(w, r, client)
-			return
+		go auth.RunKeyRotation(ctx)
+	}
+
+	scheduler := syncer.NewScheduler(func(ctx context.Context, runID string, trigger string) error {
+		result, err := syncService.Record(ctx, runID, trigger)
+		if result != nil {
+			log.Println("[INFO] Sync", runID, "added", result.Added, "updated", result.Updated, "unchanged", result.Unchanged, "failed", result.Failed)
 		}
 
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}))
+		return err
+	}, cfg.Sync.Interval, cfg.Sync.Jitter)
+
+	scheduler.Start(ctx)
+
+	serverAddr := ":" + strconv.Itoa(cfg.Port)
+
+	log.Println("[INFO] Listening on", serverAddr, "("+cfg.Env+")")
+
+	server := &http.Server{
+		Addr:    serverAddr,
+		Handler: handlers.Routes(store, scheduler),
+	}
+
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.ListenAndServe()
+	}()
+
+	// A server that failed on its own still drains the sync, but the process
+	// then exits with an error so that it gets restarted.
+	serverFailed := false
 
-	value := os.Getenv("PORT")
-	if value != "" {
-		serverAddr = ":" + value
+	select {
+	case <-ctx.Done():
+	case err := <-serverErr:
+		log.Println("[ERROR] Server stopped:", err)
+		serverFailed = true
 	}
 
-	log.Println("[INFO] Listening on", serverAddr)
+	// Stopping the signal handling cancels the running sync, and lets a second
+	// signal kill the process instead of waiting for the drain.
+	stop()
 
-	log.Fatalln(http.ListenAndServe(serverAddr, nil))
+	log.Println("[INFO] Shutting down, draining requests and sync for up to", cfg.ShutdownTimeout)
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	if err := server.Shutdown(drainCtx); err != nil {
+		log.Println("[WARN] Failed to drain requests:", err)
+	}
+
+	if err := scheduler.Shutdown(drainCtx); err != nil {
+		log.Println("[WARN] Sync did not stop in time:", err)
+	}
+
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelClose()
+
+	if err := store.Close(closeCtx); err != nil {
+		log.Println("[WARN] Failed to close database:", err)
+	}
+
+	log.Println("[INFO] Stopped")
+
+	if serverFailed {
+		os.Exit(1)
+	}
 }
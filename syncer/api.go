@@ -0,0 +1,14 @@
+package syncer
+
+import (
+	"context"
+
+	"github.com/herbievine/42-events-api/api"
+)
+
+// API is the part of the 42 API the sync depends on, implemented by
+// *api.Client.
+type API interface {
+	GetEventsByCampusID(ctx context.Context, campusID int) (api.EventsResponse, error)
+	GetEventUsersByEventId(ctx context.Context, eventID int) (api.EventUsersResponse, error)
+}
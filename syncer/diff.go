@@ -0,0 +1,89 @@
+package syncer
+
+import (
+	"slices"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+)
+
+// meaningfulFields are the fields whose changes are worth notifying users
+// about.
+var meaningfulFields = map[string]bool{
+	"begin_at":  true,
+	"end_at":    true,
+	"location":  true,
+	"cancelled": true,
+}
+
+// diffEvents lists the fields that differ between the stored and incoming
+// versions of an event. Timestamps of the intra and the roster are ignored.
+func diffEvents(stored db.Event, incoming db.Event) []db.FieldChange {
+	var changes []db.FieldChange
+
+	add := func(field string, from interface{}, to interface{}) {
+		changes = append(changes, db.FieldChange{Field: field, From: from, To: to})
+	}
+
+	if stored.Name != incoming.Name {
+		add("name", stored.Name, incoming.Name)
+	}
+
+	if stored.Description != incoming.Description {
+		add("description", stored.Description, incoming.Description)
+	}
+
+	if stored.Location != incoming.Location {
+		add("location", stored.Location, incoming.Location)
+	}
+
+	if stored.Type != incoming.Type {
+		add("type", stored.Type, incoming.Type)
+	}
+
+	if stored.Attendees != incoming.Attendees {
+		add("attendees", stored.Attendees, incoming.Attendees)
+	}
+
+	if stored.MaxAttendees != incoming.MaxAttendees {
+		add("max_attendees", stored.MaxAttendees, incoming.MaxAttendees)
+	}
+
+	if !sameTime(stored.BeginAt, incoming.BeginAt) {
+		add("begin_at", stored.BeginAt, incoming.BeginAt)
+	}
+
+	if !sameTime(stored.EndAt, incoming.EndAt) {
+		add("end_at", stored.EndAt, incoming.EndAt)
+	}
+
+	if !slices.Equal(stored.CampusIDs, incoming.CampusIDs) {
+		add("campus_ids", stored.CampusIDs, incoming.CampusIDs)
+	}
+
+	if !slices.Equal(stored.CursusIDs, incoming.CursusIDs) {
+		add("cursus_ids", stored.CursusIDs, incoming.CursusIDs)
+	}
+
+	if stored.Cancelled != incoming.Cancelled {
+		add("cancelled", stored.Cancelled, incoming.Cancelled)
+	}
+
+	return changes
+}
+
+// isMeaningful reports whether any of the changes should be notified.
+func isMeaningful(changes []db.FieldChange) bool {
+	for _, change := range changes {
+		if meaningfulFields[change.Field] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sameTime compares times at the millisecond precision kept by MongoDB.
+func sameTime(a time.Time, b time.Time) bool {
+	return a.Truncate(time.Millisecond).Equal(b.Truncate(time.Millisecond))
+}
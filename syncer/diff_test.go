@@ -0,0 +1,95 @@
+package syncer
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+)
+
+func TestDiffEvents(t *testing.T) {
+	begin := time.Date(2099, 1, 10, 14, 0, 0, 0, time.UTC)
+
+	stored := db.Event{
+		EventID:   9001,
+		Name:      "Intro to Go",
+		Location:  "Cluster 1",
+		Attendees: 3,
+		BeginAt:   begin,
+		EndAt:     begin.Add(2 * time.Hour),
+		CampusIDs: []int{1},
+		UpdatedAt: begin.Add(-time.Hour),
+	}
+
+	tests := []struct {
+		name       string
+		change     func(e *db.Event)
+		fields     []string
+		meaningful bool
+	}{
+		{
+			name:   "unchanged",
+			change: func(e *db.Event) {},
+		},
+		{
+			name:   "intra timestamp",
+			change: func(e *db.Event) { e.UpdatedAt = begin },
+		},
+		{
+			name:   "below the millisecond",
+			change: func(e *db.Event) { e.BeginAt = begin.Add(time.Microsecond) },
+		},
+		{
+			name:   "name and attendees",
+			change: func(e *db.Event) { e.Name = "Go 101"; e.Attendees = 4 },
+			fields: []string{"name", "attendees"},
+		},
+		{
+			name:       "moved",
+			change:     func(e *db.Event) { e.Location = "Amphitheatre" },
+			fields:     []string{"location"},
+			meaningful: true,
+		},
+		{
+			name:       "rescheduled",
+			change:     func(e *db.Event) { e.BeginAt = begin.Add(time.Hour); e.EndAt = begin.Add(3 * time.Hour) },
+			fields:     []string{"begin_at", "end_at"},
+			meaningful: true,
+		},
+		{
+			name:   "campuses",
+			change: func(e *db.Event) { e.CampusIDs = []int{1, 29} },
+			fields: []string{"campus_ids"},
+		},
+		{
+			name:       "cancelled",
+			change:     func(e *db.Event) { e.Cancelled = true },
+			fields:     []string{"cancelled"},
+			meaningful: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			incoming := stored
+			incoming.CampusIDs = slices.Clone(stored.CampusIDs)
+			tt.change(&incoming)
+
+			changes := diffEvents(stored, incoming)
+
+			var fields []string
+			for _, change := range changes {
+				fields = append(fields, change.Field)
+			}
+
+			if !slices.Equal(fields, tt.fields) {
+				t.Errorf("diffEvents changed %v, want %v", fields, tt.fields)
+			}
+
+			if got := isMeaningful(changes); got != tt.meaningful {
+				t.Errorf("isMeaningful = %v, want %v", got, tt.meaningful)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+)
+
+// reconcile cancels the upcoming events that no campus listed during the run
+// and notifies the users who were told about them. An event is only cancelled
+// if at least one of its campuses was listed and none of them failed, so that
+// an API error does not pass for a cancellation. Cancelled events that show
+// up again are restored when they are updated.
+func (s *Service) reconcile(ctx context.Context, state *runState) (int, error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	var campusIDs []int
+	for campusID, ok := range state.campuses {
+		if ok {
+			campusIDs = append(campusIDs, campusID)
+		}
+	}
+
+	if len(campusIDs) == 0 {
+		return 0, nil
+	}
+
+	events, err := s.store.Events().GetUpcoming(ctx, campusIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	cancelled := 0
+
+	// Each event is a checkpoint: once cancelled, its users are notified
+	// even if the run is stopped meanwhile.
+	write := context.WithoutCancel(ctx)
+
+	for _, event := range events {
+		if state.listed[event.EventID] || !state.reliable(event.CampusIDs) {
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return cancelled, err
+		}
+
+		now := time.Now()
+
+		if err := s.store.Events().Cancel(write, event.EventID, now); err != nil {
+			return cancelled, fmt.Errorf("cancel event %d: %w", event.EventID, err)
+		}
+
+		log.Println("[INFO] Cancelled event", event.EventID)
+
+		cancelled++
+
+		changes := []db.FieldChange{{Field: "cancelled", From: false, To: true}}
+
+		if err := s.recordChanges(write, event.EventID, changes, now); err != nil {
+			return cancelled, fmt.Errorf("record cancellation of event %d: %w", event.EventID, err)
+		}
+	}
+
+	return cancelled, nil
+}
+
+// reliable reports whether the listings of the given campuses can be trusted
+// to tell that an event is gone. The caller must hold the lock.
+func (s *runState) reliable(campusIDs []int) bool {
+	listed := false
+
+	for _, campusID := range campusIDs {
+		ok, synced := s.campuses[campusID]
+		if synced && !ok {
+			return false
+		}
+
+		listed = listed || ok
+	}
+
+	return listed
+}
@@ -0,0 +1,175 @@
+package syncer_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/herbievine/42-events-api/api"
+	"github.com/herbievine/42-events-api/api/fakeintra"
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/syncer"
+)
+
+// withoutEvents returns the default fixtures with the given events removed
+// from the listings of campusID.
+func withoutEvents(campusID int, eventIDs ...int) *fakeintra.Fixtures {
+	fixtures := fakeintra.DefaultFixtures()
+
+	fixtures.Events[campusID] = slices.DeleteFunc(fixtures.Events[campusID], func(event api.Event) bool {
+		return slices.Contains(eventIDs, event.ID)
+	})
+
+	return fixtures
+}
+
+// run runs a sync and fails the test on error.
+func run(t *testing.T, service *syncer.Service) *syncer.Result {
+	t.Helper()
+
+	result, err := service.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	return result
+}
+
+// cancelled reports whether the stored event is cancelled.
+func cancelled(t *testing.T, store db.Store, eventID int) bool {
+	t.Helper()
+
+	event, err := store.Events().GetOneByID(context.Background(), eventID)
+	if err != nil {
+		t.Fatalf("GetOneByID(%d): %v", eventID, err)
+	}
+
+	return event.Cancelled
+}
+
+// notificationKinds returns the kinds of the notifications of a user about
+// an event, in order of creation.
+func notificationKinds(t *testing.T, store db.Store, userID int, eventID int) []string {
+	t.Helper()
+
+	notifications, err := store.Notifications().GetManyByUserID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetManyByUserID: %v", err)
+	}
+
+	var kinds []string
+	for _, notification := range notifications {
+		if notification.EventID == eventID {
+			kinds = append(kinds, notification.Kind)
+		}
+	}
+
+	return kinds
+}
+
+func TestRunCancelsMissingEvents(t *testing.T) {
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	store := newStore(t)
+	service := syncer.NewService(fake.APIClient(), store, 2)
+
+	run(t, service)
+
+	fake.SetFixtures(withoutEvents(1, 9001))
+
+	if result := run(t, service); result.Cancelled != 1 {
+		t.Errorf("Run cancelled %d events, want 1", result.Cancelled)
+	}
+
+	if !cancelled(t, store, 9001) {
+		t.Errorf("event 9001 is not cancelled once missing from the listing")
+	}
+
+	for _, eventID := range []int{9002, 9003} {
+		if cancelled(t, store, eventID) {
+			t.Errorf("event %d was cancelled while still listed", eventID)
+		}
+	}
+
+	want := []string{db.NotificationNewEvent, db.NotificationEventCancelled}
+	if kinds := notificationKinds(t, store, 1001, 9001); !slices.Equal(kinds, want) {
+		t.Errorf("notifications of user 1001 about event 9001 = %v, want %v", kinds, want)
+	}
+
+	// A cancelled event is not cancelled again.
+	if result := run(t, service); result.Cancelled != 0 {
+		t.Errorf("next Run cancelled %d events, want 0", result.Cancelled)
+	}
+
+	if kinds := notificationKinds(t, store, 1001, 9001); !slices.Equal(kinds, want) {
+		t.Errorf("notifications of user 1001 about event 9001 after the next Run = %v, want %v", kinds, want)
+	}
+}
+
+func TestRunSkipsUnreliableListings(t *testing.T) {
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	store := newStore(t)
+	service := syncer.NewService(fake.APIClient(), store, 2)
+
+	run(t, service)
+
+	// Paris fails to list, and Online lists none of its events.
+	fixtures := withoutEvents(29, 9002, 9003)
+	delete(fixtures.Campuses, 1)
+	fake.SetFixtures(fixtures)
+
+	if result := run(t, service); result.Cancelled != 1 {
+		t.Errorf("Run cancelled %d events, want 1", result.Cancelled)
+	}
+
+	// 9001 and 9002 are listed by Paris, whose listing failed, while 9003
+	// only belongs to Online.
+	for eventID, want := range map[int]bool{9001: false, 9002: false, 9003: true} {
+		if got := cancelled(t, store, eventID); got != want {
+			t.Errorf("event %d cancelled = %v, want %v", eventID, got, want)
+		}
+	}
+}
+
+func TestRunRestoresEvents(t *testing.T) {
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	store := newStore(t)
+	service := syncer.NewService(fake.APIClient(), store, 2)
+
+	run(t, service)
+
+	fake.SetFixtures(withoutEvents(1, 9001))
+	run(t, service)
+
+	fake.SetFixtures(fakeintra.DefaultFixtures())
+
+	result := run(t, service)
+	if result.Restored != 1 || result.Cancelled != 0 {
+		t.Errorf("Run restored %d and cancelled %d events, want 1 and 0", result.Restored, result.Cancelled)
+	}
+
+	if cancelled(t, store, 9001) {
+		t.Errorf("event 9001 is still cancelled once listed again")
+	}
+
+	revisions, err := store.EventRevisions().GetManyByEventID(context.Background(), 9001)
+	if err != nil {
+		t.Fatalf("GetManyByEventID: %v", err)
+	}
+
+	var restored bool
+	for _, revision := range revisions {
+		for _, change := range revision.Changes {
+			restored = restored || (change.Field == "cancelled" && change.To == false)
+		}
+	}
+
+	if !restored {
+		t.Errorf("revisions of event 9001 = %+v, want one restoring it", revisions)
+	}
+}
@@ -0,0 +1,70 @@
+package syncer
+
+import (
+	"context"
+	"time"
+
+	"github.com/herbievine/42-events-api/db"
+)
+
+// recordChanges stores the changes of an event as a revision. If they matter
+// to attendees, every user who was notified about the event is notified again,
+// about its cancellation or its update.
+func (s *Service) recordChanges(ctx context.Context, eventID int, changes []db.FieldChange, at time.Time) error {
+	err := s.store.EventRevisions().InsertOne(ctx, db.EventRevision{
+		EventID:   eventID,
+		Changes:   changes,
+		CreatedAt: at,
+	})
+	if err != nil {
+		return err
+	}
+
+	if !isMeaningful(changes) {
+		return nil
+	}
+
+	kind := db.NotificationEventUpdated
+	for _, change := range changes {
+		if change.Field == "cancelled" && change.To == true {
+			kind = db.NotificationEventCancelled
+		}
+	}
+
+	return s.notifyEvent(ctx, eventID, kind, at)
+}
+
+// notifyEvent sends a notification of the given kind to every user who was
+// notified about the event before. A user keeps a single notification of each
+// kind per event, marked as unread again on every change.
+func (s *Service) notifyEvent(ctx context.Context, eventID int, kind string, at time.Time) error {
+	existing, err := s.store.Notifications().GetManyByEventID(ctx, eventID)
+	if err != nil {
+		return err
+	}
+
+	notified := make(map[int]bool)
+
+	var notifications []db.Notification
+	for _, notification := range existing {
+		if notified[notification.UserID] {
+			continue
+		}
+
+		notified[notification.UserID] = true
+
+		notifications = append(notifications, db.Notification{
+			UserID:    notification.UserID,
+			EventID:   eventID,
+			Kind:      kind,
+			HasRead:   false,
+			CreatedAt: at,
+		})
+	}
+
+	if len(notifications) == 0 {
+		return nil
+	}
+
+	return s.store.Notifications().UpsertMany(ctx, notifications)
+}
@@ -0,0 +1,125 @@
+package syncer_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/herbievine/42-events-api/api"
+	"github.com/herbievine/42-events-api/api/fakeintra"
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/syncer"
+)
+
+// withChange returns the default fixtures with change applied to every
+// listing of an event.
+func withChange(eventID int, change func(event *api.Event)) *fakeintra.Fixtures {
+	fixtures := fakeintra.DefaultFixtures()
+
+	for _, events := range fixtures.Events {
+		for i := range events {
+			if events[i].ID == eventID {
+				change(&events[i])
+			}
+		}
+	}
+
+	return fixtures
+}
+
+// revisionFields returns the fields changed by each revision of an event.
+func revisionFields(t *testing.T, store db.Store, eventID int) [][]string {
+	t.Helper()
+
+	revisions, err := store.EventRevisions().GetManyByEventID(context.Background(), eventID)
+	if err != nil {
+		t.Fatalf("GetManyByEventID: %v", err)
+	}
+
+	var fields [][]string
+	for _, revision := range revisions {
+		var changed []string
+		for _, change := range revision.Changes {
+			changed = append(changed, change.Field)
+		}
+
+		fields = append(fields, changed)
+	}
+
+	return fields
+}
+
+func TestRunRecordsChanges(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	store := newStore(t)
+	service := syncer.NewService(fake.APIClient(), store, 2)
+
+	run(t, service)
+
+	// A new name is recorded without notifying anyone.
+	fake.SetFixtures(withChange(9001, func(event *api.Event) { event.Name = "Go 101" }))
+
+	if result := run(t, service); result.Updated != 1 {
+		t.Errorf("Run updated %d events, want 1", result.Updated)
+	}
+
+	want := []string{db.NotificationNewEvent}
+	if kinds := notificationKinds(t, store, 1001, 9001); !slices.Equal(kinds, want) {
+		t.Errorf("notifications of user 1001 after a rename = %v, want %v", kinds, want)
+	}
+
+	// Moving the event notifies the users who were told about it.
+	fake.SetFixtures(withChange(9001, func(event *api.Event) {
+		event.Name = "Go 101"
+		event.Location = "Amphitheatre"
+	}))
+
+	run(t, service)
+
+	want = []string{db.NotificationNewEvent, db.NotificationEventUpdated}
+	if kinds := notificationKinds(t, store, 1001, 9001); !slices.Equal(kinds, want) {
+		t.Errorf("notifications of user 1001 after a move = %v, want %v", kinds, want)
+	}
+
+	if err := store.Notifications().SetRead(ctx, 1001, 9001, true); err != nil {
+		t.Fatalf("SetRead: %v", err)
+	}
+
+	// A second move brings the same notification back instead of adding one.
+	fake.SetFixtures(withChange(9001, func(event *api.Event) {
+		event.Name = "Go 101"
+		event.Location = "Cluster 2"
+	}))
+
+	run(t, service)
+
+	notifications, err := store.Notifications().GetManyByUserID(ctx, 1001)
+	if err != nil {
+		t.Fatalf("GetManyByUserID: %v", err)
+	}
+
+	var updates []db.Notification
+	for _, notification := range notifications {
+		if notification.EventID == 9001 && notification.Kind == db.NotificationEventUpdated {
+			updates = append(updates, notification)
+		}
+	}
+
+	if len(updates) != 1 || updates[0].HasRead {
+		t.Errorf("updates of event 9001 for user 1001 = %+v, want a single unread one", updates)
+	}
+
+	// Without changes, no revision is recorded.
+	if result := run(t, service); result.Updated != 0 || result.Unchanged != 3 {
+		t.Errorf("Run without changes updated %d and left %d events unchanged, want 0 and 3", result.Updated, result.Unchanged)
+	}
+
+	wantFields := [][]string{{"name"}, {"location"}, {"location"}}
+	if fields := revisionFields(t, store, 9001); !slices.EqualFunc(fields, wantFields, slices.Equal) {
+		t.Errorf("revisions of event 9001 changed %v, want %v", fields, wantFields)
+	}
+}
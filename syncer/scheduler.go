@@ -0,0 +1,190 @@
+package syncer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"math/big"
+	"sync"
+	"time"
+)
+
+const (
+	TriggerManual    string = "manual"
+	TriggerScheduled string = "scheduled"
+)
+
+var (
+	ErrAlreadyRunning = errors.New("sync already running")
+	ErrStopped        = errors.New("scheduler stopped")
+)
+
+// Job is the work performed by a single sync run. trigger tells whether the
+// run was started manually or by the schedule.
+type Job func(ctx context.Context, runID string, trigger string) error
+
+// Scheduler runs a Job every interval (plus a random jitter) and on demand,
+// making sure that at most one run is in progress at any time.
+type Scheduler struct {
+	job      Job
+	interval time.Duration
+	jitter   time.Duration
+
+	mu      sync.Mutex
+	ctx     context.Context
+	current string
+	wg      sync.WaitGroup
+}
+
+// NewScheduler creates a scheduler for job. An interval of zero or less
+// disables periodic runs, leaving only manual triggers.
+func NewScheduler(job Job, interval time.Duration, jitter time.Duration) *Scheduler {
+	return &Scheduler{
+		job:      job,
+		interval: interval,
+		jitter:   jitter,
+		ctx:      context.Background(),
+	}
+}
+
+// Start begins the periodic loop. Runs started by the scheduler, including
+// manual ones, are cancelled once ctx is done.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	if s.interval <= 0 {
+		log.Println("[INFO] Sync scheduler disabled, waiting for manual triggers")
+		return
+	}
+
+	log.Println("[INFO] Sync scheduler running every", s.interval, "with up to", s.jitter, "jitter")
+
+	s.wg.Add(1)
+	go s.loop(ctx)
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	defer s.wg.Done()
+
+	for {
+		timer := time.NewTimer(s.next())
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if id, err := s.start(TriggerScheduled); errors.Is(err, ErrAlreadyRunning) {
+			log.Println("[INFO] Skipping scheduled sync, run", id, "still in progress")
+		} else if err != nil {
+			log.Println("[WARN] Failed to start scheduled sync:", err)
+		}
+	}
+}
+
+func (s *Scheduler) next() time.Duration {
+	if s.jitter <= 0 {
+		return s.interval
+	}
+
+	n, err := rand.Int(rand.Reader, big.NewInt(int64(s.jitter)))
+	if err != nil {
+		return s.interval
+	}
+
+	return s.interval + time.Duration(n.Int64())
+}
+
+// Trigger starts a manual run in the background and returns its ID straight
+// away. If a run is already in progress, its ID is returned with
+// ErrAlreadyRunning.
+func (s *Scheduler) Trigger() (string, error) {
+	return s.start(TriggerManual)
+}
+
+func (s *Scheduler) start(trigger string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current != "" {
+		return s.current, ErrAlreadyRunning
+	}
+
+	if s.ctx.Err() != nil {
+		return "", ErrStopped
+	}
+
+	id, err := newRunID()
+	if err != nil {
+		return "", err
+	}
+
+	s.current = id
+	s.wg.Add(1)
+
+	go func(ctx context.Context) {
+		defer s.wg.Done()
+
+		start := time.Now()
+		log.Println("[INFO] Sync", id, "started", "("+trigger+")")
+
+		if err := s.job(ctx, id, trigger); err != nil {
+			log.Println("[ERROR] Sync", id, "failed after", time.Since(start), err)
+		} else {
+			log.Println("[INFO] Sync", id, "finished in", time.Since(start))
+		}
+
+		s.mu.Lock()
+		s.current = ""
+		s.mu.Unlock()
+	}(s.ctx)
+
+	return id, nil
+}
+
+// Running returns the ID of the run in progress, if any.
+func (s *Scheduler) Running() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.current, s.current != ""
+}
+
+// Wait blocks until the loop and any in-progress run have returned.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Shutdown waits like Wait, giving up when ctx is done. The context passed to
+// Start must be cancelled first for the run in progress to stop at its next
+// checkpoint.
+func (s *Scheduler) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func newRunID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
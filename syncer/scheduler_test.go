@@ -0,0 +1,146 @@
+package syncer_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/herbievine/42-events-api/syncer"
+)
+
+// blockingJob returns a job that signals on started when a run begins, then
+// blocks until release is closed or its context is done. Runs that start
+// while started is full are not signalled.
+func blockingJob(calls *atomic.Int32, started chan<- string, release <-chan struct{}) syncer.Job {
+	return func(ctx context.Context, runID string, trigger string) error {
+		calls.Add(1)
+
+		select {
+		case started <- trigger:
+		default:
+		}
+
+		select {
+		case <-release:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func TestSchedulerSkipsTicksWhileRunning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	started := make(chan string, 10)
+	release := make(chan struct{})
+
+	scheduler := syncer.NewScheduler(blockingJob(&calls, started, release), 5*time.Millisecond, 0)
+	scheduler.Start(ctx)
+
+	select {
+	case trigger := <-started:
+		if trigger != syncer.TriggerScheduled {
+			t.Errorf("first run was triggered %q, want %q", trigger, syncer.TriggerScheduled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no run started")
+	}
+
+	running, ok := scheduler.Running()
+	if !ok {
+		t.Fatalf("Running reported no run in progress")
+	}
+
+	// Let several ticks pass while the first run is blocked.
+	time.Sleep(50 * time.Millisecond)
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("job ran %d times while a run was in progress, want 1", n)
+	}
+
+	if id, err := scheduler.Trigger(); !errors.Is(err, syncer.ErrAlreadyRunning) || id != running {
+		t.Errorf("Trigger = %q, %v, want %q, ErrAlreadyRunning", id, err, running)
+	}
+
+	close(release)
+
+	select {
+	case <-started:
+	case <-time.After(time.Second):
+		t.Fatalf("no run started once the first one finished")
+	}
+
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Second)
+	defer cancelShutdown()
+
+	if err := scheduler.Shutdown(shutdownCtx); err != nil {
+		t.Errorf("Shutdown: %v", err)
+	}
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var calls atomic.Int32
+	started := make(chan string, 1)
+	result := make(chan error, 1)
+
+	job := blockingJob(&calls, started, nil)
+
+	scheduler := syncer.NewScheduler(func(ctx context.Context, runID string, trigger string) error {
+		err := job(ctx, runID, trigger)
+		result <- err
+
+		return err
+	}, 0, 0)
+	scheduler.Start(ctx)
+
+	if _, err := scheduler.Trigger(); err != nil {
+		t.Fatalf("Trigger: %v", err)
+	}
+
+	<-started
+
+	// The run only stops once its context is done, so it outlives a drain that
+	// does not cancel it.
+	shortCtx, cancelShort := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelShort()
+
+	if err := scheduler.Shutdown(shortCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown before cancelling = %v, want DeadlineExceeded", err)
+	}
+
+	cancel()
+
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), time.Second)
+	defer cancelShutdown()
+
+	if err := scheduler.Shutdown(shutdownCtx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("run returned %v, want context.Canceled", err)
+		}
+	default:
+		t.Errorf("Shutdown returned before the run did")
+	}
+
+	if _, ok := scheduler.Running(); ok {
+		t.Errorf("Running reported a run in progress after Shutdown")
+	}
+
+	if _, err := scheduler.Trigger(); !errors.Is(err, syncer.ErrStopped) {
+		t.Errorf("Trigger after Shutdown = %v, want ErrStopped", err)
+	}
+}
@@ -0,0 +1,522 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/herbievine/42-events-api/api"
+	"github.com/herbievine/42-events-api/db"
+)
+
+type Result struct {
+	Added     int                `json:"added"`
+	Updated   int                `json:"updated"`
+	Restored  int                `json:"restored"`
+	Cancelled int                `json:"cancelled"`
+	Unchanged int                `json:"unchanged"`
+	Skipped   int                `json:"skipped"`
+	Failed    int                `json:"failed"`
+	APICalls  int                `json:"api_calls"`
+	Retries   int                `json:"retries"`
+	Campuses  []db.SyncRunCampus `json:"campuses"`
+}
+
+func (r *Result) add(campus db.SyncRunCampus) {
+	r.Added += campus.Added
+	r.Updated += campus.Updated
+	r.Restored += campus.Restored
+	r.Unchanged += campus.Unchanged
+	r.Skipped += campus.Skipped
+	r.Failed += campus.Failed
+	r.APICalls += campus.APICalls
+	r.Retries += campus.Retries
+	r.Campuses = append(r.Campuses, campus)
+}
+
+// runState tracks what the campuses of a run saw so far. It is shared by the
+// workers of the run.
+type runState struct {
+	mu sync.Mutex
+
+	// known holds the campuses of the run.
+	known map[int]db.Campus
+
+	// claimed holds the events already handled, as an event may be listed by
+	// several campuses.
+	claimed map[int]bool
+
+	// listed holds every event returned by the API, and campuses whether the
+	// events of each campus could be listed.
+	listed   map[int]bool
+	campuses map[int]bool
+}
+
+func newRunState(campuses []db.Campus) *runState {
+	known := make(map[int]db.Campus, len(campuses))
+	for _, campus := range campuses {
+		known[campus.CampusID] = campus
+	}
+
+	return &runState{
+		known:    known,
+		claimed:  make(map[int]bool),
+		listed:   make(map[int]bool),
+		campuses: make(map[int]bool),
+	}
+}
+
+// listedBy returns the campuses of the run among campusIDs, the campuses
+// listing an event.
+func (s *runState) listedBy(campusIDs []int) []db.Campus {
+	var campuses []db.Campus
+	for _, campusID := range campusIDs {
+		if campus, ok := s.known[campusID]; ok {
+			campuses = append(campuses, campus)
+		}
+	}
+
+	return campuses
+}
+
+// claim marks eventID as handled, reporting false if it already was.
+func (s *runState) claim(eventID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.claimed[eventID] {
+		return false
+	}
+
+	s.claimed[eventID] = true
+
+	return true
+}
+
+// list records the outcome of listing the events of a campus.
+func (s *runState) list(campusID int, events api.EventsResponse, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.campuses[campusID] = ok
+
+	for _, event := range events {
+		s.listed[event.ID] = true
+	}
+}
+
+// Service pulls upcoming events from the 42 API into the store.
+type Service struct {
+	api     API
+	store   db.Store
+	workers int
+}
+
+// NewService creates a sync service syncing up to workers campuses at once.
+func NewService(api API, store db.Store, workers int) *Service {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &Service{
+		api:     api,
+		store:   store,
+		workers: workers,
+	}
+}
+
+// Run syncs the upcoming events of every known campus, notifying the users
+// of the campuses listing new events. Campuses are synced by a pool of workers
+// sharing the client's rate limiter. A campus that fails is recorded in the
+// result and does not stop the others. Run stops early when ctx is cancelled,
+// at a checkpoint between the writes of campuses and events.
+func (s *Service) Run(ctx context.Context) (*Result, error) {
+	var result Result
+
+	campuses, err := s.store.Campus().GetMany(ctx)
+	if err != nil {
+		return &result, err
+	}
+
+	results := make([]db.SyncRunCampus, len(campuses))
+	done := make([]bool, len(campuses))
+	state := newRunState(campuses)
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < s.workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				results[i] = s.runCampus(ctx, campuses[i], state)
+				done[i] = true
+			}
+		}()
+	}
+
+dispatch:
+	for i := range campuses {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	result.Campuses = make([]db.SyncRunCampus, 0, len(campuses))
+	for i := range campuses {
+		if done[i] {
+			result.add(results[i])
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return &result, err
+	}
+
+	cancelled, err := s.reconcile(ctx, state)
+	result.Cancelled = cancelled
+
+	return &result, err
+}
+
+// runCampus syncs a campus, recording its API usage and failure in the
+// returned result.
+func (s *Service) runCampus(ctx context.Context, campus db.Campus, state *runState) db.SyncRunCampus {
+	var stats api.Stats
+
+	result, err := s.syncCampus(api.WithStats(ctx, &stats), campus, state)
+
+	result.APICalls = int(stats.Calls.Load())
+	result.Retries = int(stats.Retries.Load())
+
+	if err != nil {
+		log.Println("[WARN] Failed to sync campus", campus.CampusID, err)
+
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	return result
+}
+
+// Record runs a sync like Run, storing its progress and outcome as a sync
+// run identified by runID.
+func (s *Service) Record(ctx context.Context, runID string, trigger string) (*Result, error) {
+	run := db.SyncRun{
+		RunID:     runID,
+		Trigger:   trigger,
+		Status:    db.SyncRunRunning,
+		StartedAt: time.Now(),
+		Campuses:  []db.SyncRunCampus{},
+		Errors:    []string{},
+	}
+
+	if err := s.store.SyncRuns().InsertOne(ctx, run); err != nil {
+		return nil, err
+	}
+
+	result, err := s.Run(ctx)
+
+	run.FinishedAt = time.Now()
+	run.Status = db.SyncRunSucceeded
+
+	if result != nil {
+		run.Status = campusesStatus(result.Campuses)
+		run.Added = result.Added
+		run.Updated = result.Updated
+		run.Restored = result.Restored
+		run.Cancelled = result.Cancelled
+		run.Unchanged = result.Unchanged
+		run.Skipped = result.Skipped
+		run.Failed = result.Failed
+		run.APICalls = result.APICalls
+		run.Retries = result.Retries
+		run.Campuses = result.Campuses
+	}
+
+	if errors.Is(err, context.Canceled) {
+		run.Status = db.SyncRunCancelled
+		run.Errors = append(run.Errors, err.Error())
+	} else if err != nil {
+		run.Status = db.SyncRunFailed
+		run.Errors = append(run.Errors, err.Error())
+	}
+
+	// The outcome is saved even when the run was cancelled.
+	if err := s.store.SyncRuns().ReplaceOne(context.WithoutCancel(ctx), run); err != nil {
+		log.Println("[WARN] Failed to save sync run", runID, err)
+	}
+
+	return result, err
+}
+
+// campusesStatus returns the status of a run from the errors of its
+// campuses.
+func campusesStatus(campuses []db.SyncRunCampus) string {
+	failed := 0
+	for _, campus := range campuses {
+		if len(campus.Errors) > 0 {
+			failed++
+		}
+	}
+
+	switch {
+	case failed == 0:
+		return db.SyncRunSucceeded
+	case failed == len(campuses):
+		return db.SyncRunFailed
+	default:
+		return db.SyncRunPartial
+	}
+}
+
+func (s *Service) syncCampus(ctx context.Context, campus db.Campus, state *runState) (db.SyncRunCampus, error) {
+	result := db.SyncRunCampus{
+		CampusID: campus.CampusID,
+		Name:     campus.Name,
+		Errors:   []string{},
+	}
+
+	campusEvents, err := s.api.GetEventsByCampusID(ctx, campus.CampusID)
+
+	state.list(campus.CampusID, campusEvents, err == nil)
+
+	if err != nil {
+		return result, err
+	}
+
+	var incoming []db.Event
+
+	for _, event := range campusEvents {
+		if event.EndAt.Before(time.Now()) || !state.claim(event.ID) {
+			result.Skipped++
+			continue
+		}
+
+		e := toEvent(event)
+
+		// The event is handled by the first campus listing it, on behalf of
+		// every campus listing it.
+		fetchAttendees := campus.FetchAttendees
+		for _, listing := range state.listedBy(e.CampusIDs) {
+			fetchAttendees = fetchAttendees || listing.FetchAttendees
+		}
+
+		if fetchAttendees {
+			attendeeIDs, err := s.fetchAttendees(ctx, event.ID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return result, ctx.Err()
+				}
+
+				log.Println("[WARN] Failed to get event users", event.ID, err)
+
+				result.Failed++
+				result.Errors = append(result.Errors, fmt.Sprintf("get users of event %d: %v", event.ID, err))
+				continue
+			}
+
+			e.Attendees = len(attendeeIDs)
+			e.AttendeeIDs = attendeeIDs
+		}
+
+		incoming = append(incoming, e)
+	}
+
+	if len(incoming) == 0 {
+		return result, nil
+	}
+
+	// Past this checkpoint the events of the campus are written along with
+	// their notifications even if the run is stopped, so that a shutdown
+	// does not leave a batch half written.
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	ctx = context.WithoutCancel(ctx)
+
+	eventIDs := make([]int, 0, len(incoming))
+	for _, event := range incoming {
+		eventIDs = append(eventIDs, event.EventID)
+	}
+
+	storedEvents, err := s.store.Events().GetManyByIDs(ctx, eventIDs)
+	if err != nil {
+		return result, err
+	}
+
+	stored := make(map[int]db.Event, len(storedEvents))
+	for _, event := range storedEvents {
+		stored[event.EventID] = event
+	}
+
+	// New events are notified before they are written, and notifications are
+	// only inserted once, so that a sync failing in between notifies them on
+	// the next run instead of never.
+	campusUsers := make(map[int][]db.User)
+
+	var notifications []db.Notification
+
+	for _, event := range incoming {
+		if _, ok := stored[event.EventID]; ok {
+			continue
+		}
+
+		users, err := s.listingUsers(ctx, campus, event, state, campusUsers)
+		if err != nil {
+			result.Failed += len(incoming)
+			return result, err
+		}
+
+		for _, user := range users {
+			notifications = append(notifications, db.Notification{
+				UserID:    user.UserID,
+				EventID:   event.EventID,
+				Kind:      db.NotificationNewEvent,
+				HasRead:   false,
+				CreatedAt: time.Now(),
+				DeletedAt: time.Time{},
+			})
+		}
+	}
+
+	if err := s.store.Notifications().InsertManyIfAbsent(ctx, notifications); err != nil {
+		result.Failed += len(incoming)
+		return result, err
+	}
+
+	insertedIDs, err := s.store.Events().UpsertMany(ctx, incoming)
+	if err != nil {
+		result.Failed += len(incoming)
+		return result, err
+	}
+
+	inserted := make(map[int]bool, len(insertedIDs))
+	for _, eventID := range insertedIDs {
+		inserted[eventID] = true
+	}
+
+	for _, event := range incoming {
+		if inserted[event.EventID] {
+			result.Added++
+			continue
+		}
+
+		// Without a stored version, the event was inserted by a concurrent
+		// run.
+		previous, ok := stored[event.EventID]
+		if !ok {
+			result.Unchanged++
+			continue
+		}
+
+		changes := diffEvents(previous, event)
+		if len(changes) == 0 {
+			result.Unchanged++
+			continue
+		}
+
+		if err := s.recordChanges(ctx, event.EventID, changes, time.Now()); err != nil {
+			log.Println("[WARN] Failed to record changes of event", event.EventID, err)
+
+			result.Errors = append(result.Errors, fmt.Sprintf("record changes of event %d: %v", event.EventID, err))
+		}
+
+		if previous.Cancelled {
+			log.Println("[INFO] Restored event", event.EventID)
+
+			result.Restored++
+		} else {
+			log.Println("[INFO] Updated event", event.EventID)
+
+			result.Updated++
+		}
+	}
+
+	return result, nil
+}
+
+// listingUsers returns the users of campus and of the other campuses of the
+// run listing event, each once. The users of each campus are cached in
+// campusUsers.
+func (s *Service) listingUsers(ctx context.Context, campus db.Campus, event db.Event, state *runState, campusUsers map[int][]db.User) ([]db.User, error) {
+	campuses := append([]db.Campus{campus}, state.listedBy(event.CampusIDs)...)
+
+	seenCampuses := make(map[int]bool, len(campuses))
+	seenUsers := make(map[int]bool)
+
+	var users []db.User
+	for _, c := range campuses {
+		if seenCampuses[c.CampusID] {
+			continue
+		}
+
+		seenCampuses[c.CampusID] = true
+
+		cached, ok := campusUsers[c.CampusID]
+		if !ok {
+			var err error
+			if cached, err = s.store.Users().GetManyByCampusID(ctx, c.CampusID); err != nil {
+				return nil, err
+			}
+
+			campusUsers[c.CampusID] = cached
+		}
+
+		for _, user := range cached {
+			if !seenUsers[user.UserID] {
+				seenUsers[user.UserID] = true
+				users = append(users, user)
+			}
+		}
+	}
+
+	return users, nil
+}
+
+// fetchAttendees walks every page of the event's subscribers and returns
+// their user IDs.
+func (s *Service) fetchAttendees(ctx context.Context, eventID int) ([]int, error) {
+	eventUsers, err := s.api.GetEventUsersByEventId(ctx, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	attendeeIDs := make([]int, 0, len(eventUsers))
+	for _, eventUser := range eventUsers {
+		attendeeIDs = append(attendeeIDs, eventUser.UserID)
+	}
+
+	return attendeeIDs, nil
+}
+
+// toEvent converts an event of the intra, counting attendees from the
+// subscriber count it reports.
+func toEvent(event api.Event) db.Event {
+	return db.Event{
+		EventID:      event.ID,
+		Name:         event.Name,
+		Description:  event.Description,
+		Location:     event.Location,
+		Type:         event.Kind,
+		Attendees:    event.NbrSubscribers,
+		MaxAttendees: event.MaxPeople,
+		BeginAt:      event.BeginAt,
+		EndAt:        event.EndAt,
+		CampusIDs:    event.CampusIds,
+		CursusIDs:    event.CursusIds,
+		CreatedAt:    event.CreatedAt,
+		UpdatedAt:    event.UpdatedAt,
+	}
+}
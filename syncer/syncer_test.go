@@ -0,0 +1,189 @@
+package syncer_test
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/herbievine/42-events-api/api/fakeintra"
+	"github.com/herbievine/42-events-api/db"
+	"github.com/herbievine/42-events-api/syncer"
+)
+
+// newStore returns an in-memory store with the campuses of the default
+// fixtures, Online crawling its rosters, and a user on each.
+func newStore(t *testing.T) db.Store {
+	ctx := context.Background()
+	store := db.NewMemoryStore()
+
+	campuses := []db.Campus{
+		{CampusID: 1, Name: "Paris"},
+		{CampusID: 29, Name: "Online", FetchAttendees: true},
+	}
+
+	for _, campus := range campuses {
+		if err := store.Campus().InsertOne(ctx, campus); err != nil {
+			t.Fatalf("InsertOne: %v", err)
+		}
+	}
+
+	users := []db.User{
+		{UserID: 1001, Login: "jdoe", CampusIDs: []int{1}},
+		{UserID: 1002, Login: "asmith", CampusIDs: []int{29}},
+	}
+
+	for _, user := range users {
+		if err := store.Users().InsertOne(ctx, user); err != nil {
+			t.Fatalf("InsertOne: %v", err)
+		}
+	}
+
+	return store
+}
+
+// notifiedEvents returns the IDs of the events the user was notified about,
+// sorted.
+func notifiedEvents(t *testing.T, store db.Store, userID int) []int {
+	notifications, err := store.Notifications().GetManyByUserID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetManyByUserID: %v", err)
+	}
+
+	var eventIDs []int
+	for _, notification := range notifications {
+		eventIDs = append(eventIDs, notification.EventID)
+	}
+
+	sort.Ints(eventIDs)
+
+	return eventIDs
+}
+
+func TestRun(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	fake.MaxPageSize = 1
+
+	store := newStore(t)
+	service := syncer.NewService(fake.APIClient(), store, 2)
+
+	result, err := service.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if result.Added != 3 || result.Failed != 0 {
+		t.Errorf("Run added %d and failed %d events, want 3 and 0", result.Added, result.Failed)
+	}
+
+	// Only the 2 upcoming events of campus 1 are listed.
+	if n := fake.Requests("/v2/campus/1/events"); n != 2 {
+		t.Errorf("Run listed the events of campus 1 in %d requests, want a page per upcoming event", n)
+	}
+
+	events, err := store.Events().GetManyByIDs(ctx, []int{9000, 9001, 9002, 9003})
+	if err != nil {
+		t.Fatalf("GetManyByIDs: %v", err)
+	}
+
+	stored := make(map[int]db.Event)
+	for _, event := range events {
+		stored[event.EventID] = event
+	}
+
+	if _, ok := stored[9000]; ok || len(stored) != 3 {
+		t.Errorf("Run stored %d events, want the 3 upcoming ones", len(stored))
+	}
+
+	// The hackathon is listed by both campuses, and Online crawls rosters.
+	if got := stored[9002].AttendeeIDs; !slices.Equal(got, []int{1001, 1004}) {
+		t.Errorf("attendees of event 9002 = %v, want [1001 1004]", got)
+	}
+
+	if got := stored[9001]; got.AttendeeIDs != nil || got.Attendees != 3 {
+		t.Errorf("event 9001 has %d attendees %v, want 3 from the subscriber count", got.Attendees, got.AttendeeIDs)
+	}
+
+	if got := notifiedEvents(t, store, 1001); !slices.Equal(got, []int{9001, 9002}) {
+		t.Errorf("user 1001 was notified about %v, want [9001 9002]", got)
+	}
+
+	if got := notifiedEvents(t, store, 1002); !slices.Equal(got, []int{9002, 9003}) {
+		t.Errorf("user 1002 was notified about %v, want [9002 9003]", got)
+	}
+
+	result, err = service.Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if result.Added != 0 || result.Unchanged != 3 {
+		t.Errorf("second Run added %d and left %d events unchanged, want 0 and 3", result.Added, result.Unchanged)
+	}
+
+	if got := notifiedEvents(t, store, 1001); len(got) != 2 {
+		t.Errorf("user 1001 has %d notifications after the second Run, want 2", len(got))
+	}
+}
+
+func TestRunRetries(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	fake.RetryAfter = 0
+	fake.FailNext(1, 429)
+	fake.FailNext(1, 503)
+
+	store := newStore(t)
+
+	result, err := syncer.NewService(fake.APIClient(), store, 1).Run(ctx)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if result.Retries != 2 {
+		t.Errorf("Run retried %d requests, want 2", result.Retries)
+	}
+
+	if result.Added != 3 {
+		t.Errorf("Run added %d events, want 3", result.Added)
+	}
+
+	for _, campus := range result.Campuses {
+		if len(campus.Errors) > 0 {
+			t.Errorf("campus %d failed: %v", campus.CampusID, campus.Errors)
+		}
+	}
+}
+
+func TestRecordPartial(t *testing.T) {
+	ctx := context.Background()
+
+	fake := fakeintra.New(fakeintra.DefaultFixtures())
+	defer fake.Close()
+
+	store := newStore(t)
+
+	if err := store.Campus().InsertOne(ctx, db.Campus{CampusID: 404, Name: "Unknown"}); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+
+	if _, err := syncer.NewService(fake.APIClient(), store, 1).Record(ctx, "run", "manual"); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+
+	run, err := store.SyncRuns().GetOneByID(ctx, "run")
+	if err != nil {
+		t.Fatalf("GetOneByID: %v", err)
+	}
+
+	if run.Status != db.SyncRunPartial {
+		t.Errorf("status of a run with a failed campus = %q, want %q", run.Status, db.SyncRunPartial)
+	}
+}